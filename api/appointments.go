@@ -0,0 +1,504 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errAppointmentsDisabled = errors.New("This topic does not accept appointments")
+	errSlotUnavailable      = errors.New("The requested slot is not available")
+	errAlreadyCheckedIn     = errors.New("This appointment has already been checked in or cancelled")
+	errSlotFull             = errors.New("The requested slot is fully booked")
+)
+
+type appointmentSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Capacity  int       `json:"capacity"`
+	Booked    int       `json:"booked"`
+	Available bool      `json:"available"`
+}
+
+func validSlotPolicy(topic models.Topic) error {
+	if topic.SlotMinutes < 0 || topic.SlotMinutes > 24*60 {
+		return fmt.Errorf("slotMinutes must be between 0 and 1440")
+	}
+	if topic.SlotCapacity < 1 {
+		return fmt.Errorf("slotCapacity must be at least 1")
+	}
+	if topic.BookingLeadMinutes < 0 || topic.CancelCutoffMinutes < 0 || topic.CheckInGraceMinutes < 0 {
+		return fmt.Errorf("bookingLeadMinutes, cancelCutoffMinutes and checkInGraceMinutes must not be negative")
+	}
+	if topic.BookingDays < 1 {
+		return fmt.Errorf("bookingDays must be at least 1")
+	}
+	return nil
+}
+
+func parseClock(value string, day time.Time) (time.Time, error) {
+	layout := "15:04"
+	if len(value) >= 8 {
+		value = value[:8]
+		layout = "15:04:05"
+	}
+	clock, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, day.Location()), nil
+}
+
+func parseServiceDay(value string) (time.Time, error) {
+	startOfDay, _ := helpers.GetStartAndEndOfDay()
+	if value == "" {
+		return startOfDay, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, startOfDay.Location())
+}
+
+func topicSlots(db *gorm.DB, topic models.Topic, day time.Time, excludeID int) ([]appointmentSlot, error) {
+	if topic.SlotMinutes <= 0 {
+		return nil, errAppointmentsDisabled
+	}
+
+	var counters []models.Counter
+	if err := db.Where("id IN (SELECT counter_id FROM counter_topics WHERE topic_id = ?)", topic.ID).
+		Find(&counters).Error; err != nil {
+		return nil, err
+	}
+
+	type window struct{ open, close time.Time }
+	var windows []window
+	var earliest, latest time.Time
+	for _, counter := range counters {
+		open, err := parseClock(counter.TimeOpened, day)
+		if err != nil {
+			continue
+		}
+		closed, err := parseClock(counter.TimeClosed, day)
+		if err != nil || !closed.After(open) {
+			continue
+		}
+		windows = append(windows, window{open, closed})
+		if earliest.IsZero() || open.Before(earliest) {
+			earliest = open
+		}
+		if closed.After(latest) {
+			latest = closed
+		}
+	}
+	if len(windows) == 0 {
+		return []appointmentSlot{}, nil
+	}
+
+	var bookings []struct {
+		SlotStart time.Time
+		Count     int
+	}
+	if err := db.Model(&models.Appointment{}).
+		Select("slot_start, COUNT(*) AS count").
+		Where("topic_id = ? AND slot_start >= ? AND slot_start < ? AND status IN ? AND id != ?",
+			topic.ID, day, day.AddDate(0, 0, 1),
+			[]helpers.APPOINTMENT_STATUS{helpers.APPOINTMENT_BOOKED, helpers.APPOINTMENT_CHECKED_IN}, excludeID).
+		Group("slot_start").
+		Scan(&bookings).Error; err != nil {
+		return nil, err
+	}
+	booked := map[int64]int{}
+	for _, booking := range bookings {
+		booked[booking.SlotStart.Unix()] = booking.Count
+	}
+
+	now := time.Now()
+	startOfToday, _ := helpers.GetStartAndEndOfDay()
+	lastDay := startOfToday.AddDate(0, 0, topic.BookingDays+1)
+	duration := time.Duration(topic.SlotMinutes) * time.Minute
+
+	slots := []appointmentSlot{}
+	for start := earliest; !start.Add(duration).After(latest); start = start.Add(duration) {
+		end := start.Add(duration)
+		open := false
+		for _, w := range windows {
+			if !start.Before(w.open) && !end.After(w.close) {
+				open = true
+				break
+			}
+		}
+		if !open {
+			continue
+		}
+		slot := appointmentSlot{Start: start, End: end, Capacity: topic.SlotCapacity, Booked: booked[start.Unix()]}
+		slot.Available = slot.Booked < slot.Capacity &&
+			!start.Before(now.Add(time.Duration(topic.BookingLeadMinutes)*time.Minute)) &&
+			start.Before(lastDay)
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+func reserveSlot(tx *gorm.DB, topic models.Topic, start time.Time, excludeID int) (appointmentSlot, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", topic.ID, int32(start.Unix()/60)).Error; err != nil {
+		return appointmentSlot{}, err
+	}
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	slots, err := topicSlots(tx, topic, day, excludeID)
+	if err != nil {
+		return appointmentSlot{}, err
+	}
+	for _, slot := range slots {
+		if !slot.Start.Equal(start) {
+			continue
+		}
+		if slot.Booked >= slot.Capacity {
+			return slot, errSlotFull
+		}
+		if !slot.Available {
+			return slot, errSlotUnavailable
+		}
+		return slot, nil
+	}
+	return appointmentSlot{}, errSlotUnavailable
+}
+
+func slotErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errAppointmentsDisabled), errors.Is(err, errSlotUnavailable):
+		return http.StatusBadRequest
+	case errors.Is(err, errSlotFull):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func findOwnAppointment(c *gin.Context, db *gorm.DB) (models.Appointment, bool) {
+	userClaims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return models.Appointment{}, false
+	}
+	studentID, _ := userClaims["studentId"].(string)
+
+	var appointment models.Appointment
+	if err := db.Preload("Topic").First(&appointment, c.Param("id")).Error; err != nil {
+		helpers.FormatErrorResponse(c, http.StatusNotFound, "Appointment not found")
+		return appointment, false
+	}
+	if studentID == "" || appointment.StudentID != studentID {
+		helpers.FormatErrorResponse(c, http.StatusForbidden, "This appointment belongs to another student")
+		return appointment, false
+	}
+	return appointment, true
+}
+
+func beforeCancelCutoff(appointment models.Appointment) bool {
+	cutoff := appointment.SlotStart.Add(-time.Duration(appointment.Topic.CancelCutoffMinutes) * time.Minute)
+	return time.Now().Before(cutoff)
+}
+
+func broadcastAppointment(hub *Hub, event string, appointment models.Appointment) {
+	message, _ := json.Marshal(map[string]interface{}{
+		"event": event,
+		"data":  appointment,
+	})
+	hub.broadcast <- message
+}
+
+func GetTopicSlots(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var topic models.Topic
+		if err := db.First(&topic, c.Param("id")).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
+			return
+		}
+		day, err := parseServiceDay(c.Query("date"))
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+
+		slots, err := topicSlots(db, topic, day, 0)
+		if err != nil {
+			helpers.FormatErrorResponse(c, slotErrorStatus(err), err.Error())
+			return
+		}
+		helpers.FormatSuccessResponse(c, slots)
+	}
+}
+
+func GetMyAppointments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		studentID, _ := userClaims["studentId"].(string)
+
+		startOfDay, _ := helpers.GetStartAndEndOfDay()
+		var appointments []models.Appointment
+		if err := db.Preload("Topic").Preload("Queue").
+			Where("student_id = ? AND slot_start >= ?", studentID, startOfDay).
+			Order("slot_start ASC").
+			Find(&appointments).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch appointments")
+			return
+		}
+		helpers.FormatSuccessResponse(c, appointments)
+	}
+}
+
+func GetAppointments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		day, err := parseServiceDay(c.Query("date"))
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+
+		query := db.Preload("Topic").Preload("Queue").
+			Where("slot_start >= ? AND slot_start < ?", day, day.AddDate(0, 0, 1))
+		if topicID := c.Query("topic"); topicID != "" {
+			query = query.Where("topic_id = ?", topicID)
+		}
+
+		var appointments []models.Appointment
+		if err := query.Order("slot_start ASC, id ASC").Find(&appointments).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch appointments")
+			return
+		}
+		helpers.FormatSuccessResponse(c, appointments)
+	}
+}
+
+func CreateAppointment(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			Topic     int       `json:"topic"`
+			SlotStart time.Time `json:"slotStart"`
+			Note      *string   `json:"note"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || body.Topic == 0 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		studentID, _ := userClaims["studentId"].(string)
+		if studentID == "" {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "Only students can book appointments")
+			return
+		}
+		firstName, _ := userClaims["firstName"].(string)
+		lastName, _ := userClaims["lastName"].(string)
+		email, _ := userClaims["email"].(string)
+
+		var topic models.Topic
+		if err := db.First(&topic, body.Topic).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
+			return
+		}
+
+		start := body.SlotStart.In(helpers.GetBangkokTime().Location())
+		appointment := models.Appointment{
+			TopicID:   topic.ID,
+			StudentID: studentID,
+			Firstname: firstName,
+			Lastname:  lastName,
+			Email:     email,
+			Note:      body.Note,
+			SlotStart: start,
+			Status:    helpers.APPOINTMENT_BOOKED,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			slot, err := reserveSlot(tx, topic, start, 0)
+			if err != nil {
+				return err
+			}
+			appointment.SlotEnd = slot.End
+			return tx.Create(&appointment).Error
+		})
+		if err != nil {
+			helpers.FormatErrorResponse(c, slotErrorStatus(err), err.Error())
+			return
+		}
+		appointment.Topic = topic
+
+		broadcastAppointment(hub, "addAppointment", appointment)
+		helpers.FormatSuccessResponse(c, appointment)
+	}
+}
+
+func RescheduleAppointment(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			SlotStart time.Time `json:"slotStart"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		appointment, ok := findOwnAppointment(c, db)
+		if !ok {
+			return
+		}
+		if appointment.Status != helpers.APPOINTMENT_BOOKED {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only booked appointments can be rescheduled")
+			return
+		}
+		if !beforeCancelCutoff(appointment) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "The cancellation cutoff for this appointment has passed")
+			return
+		}
+
+		start := body.SlotStart.In(helpers.GetBangkokTime().Location())
+		err := db.Transaction(func(tx *gorm.DB) error {
+			slot, err := reserveSlot(tx, appointment.Topic, start, appointment.ID)
+			if err != nil {
+				return err
+			}
+			appointment.SlotStart = slot.Start
+			appointment.SlotEnd = slot.End
+			return tx.Model(&appointment).Updates(map[string]interface{}{
+				"slot_start": slot.Start,
+				"slot_end":   slot.End,
+			}).Error
+		})
+		if err != nil {
+			helpers.FormatErrorResponse(c, slotErrorStatus(err), err.Error())
+			return
+		}
+
+		broadcastAppointment(hub, "updateAppointment", appointment)
+		helpers.FormatSuccessResponse(c, appointment)
+	}
+}
+
+func CancelAppointment(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment, ok := findOwnAppointment(c, db)
+		if !ok {
+			return
+		}
+		if appointment.Status != helpers.APPOINTMENT_BOOKED {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only booked appointments can be cancelled")
+			return
+		}
+		if !beforeCancelCutoff(appointment) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "The cancellation cutoff for this appointment has passed")
+			return
+		}
+
+		if err := db.Model(&appointment).Update("status", helpers.APPOINTMENT_CANCELLED).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to cancel appointment")
+			return
+		}
+
+		broadcastAppointment(hub, "cancelAppointment", appointment)
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Appointment cancelled successfully"})
+	}
+}
+
+func CheckInAppointment(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment, ok := findOwnAppointment(c, db)
+		if !ok {
+			return
+		}
+		if appointment.Status != helpers.APPOINTMENT_BOOKED {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only booked appointments can be checked in")
+			return
+		}
+		startOfDay, endOfDay := helpers.GetStartAndEndOfDay()
+		if appointment.SlotStart.Before(startOfDay) || !appointment.SlotStart.Before(endOfDay) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Appointments can only be checked in on the day of the slot")
+			return
+		}
+		deadline := appointment.SlotStart.Add(time.Duration(appointment.Topic.CheckInGraceMinutes) * time.Minute)
+		if time.Now().After(deadline) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "The check-in window for this appointment has passed")
+			return
+		}
+
+		studentID := appointment.StudentID
+		slotStart := appointment.SlotStart
+		queue := models.Queue{
+			StudentID:    &studentID,
+			Firstname:    appointment.Firstname,
+			Lastname:     appointment.Lastname,
+			TopicID:      appointment.TopicID,
+			Note:         appointment.Note,
+			Priority:     helpers.PRIORITY_APPOINTMENT,
+			PriorityFrom: &slotStart,
+			ServiceDate:  startOfDay,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&appointment).Where("status = ?", helpers.APPOINTMENT_BOOKED).
+				Update("status", helpers.APPOINTMENT_CHECKED_IN)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errAlreadyCheckedIn
+			}
+			if err := checkTicketLimits(tx, queue); err != nil {
+				return err
+			}
+			queueNo, err := allocateQueueNo(tx, appointment.Topic, startOfDay)
+			if err != nil {
+				return err
+			}
+			queue.No = queueNo
+			if err := tx.Create(&queue).Error; err != nil {
+				return err
+			}
+			if err := RecordQueueEvent(tx, queue, helpers.QUEUE_EVENT_CREATED, nil, queueActor(c, tx), appointment.ID); err != nil {
+				return err
+			}
+			appointment.QueueID = &queue.ID
+			return tx.Model(&appointment).Update("queue_id", queue.ID).Error
+		})
+		if ticketLimitErrorResponse(c, err) {
+			return
+		}
+		if errors.Is(err, errAlreadyCheckedIn) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check in appointment")
+			return
+		}
+
+		countWaitingAfterInProgress, err := FindWaitingQueue(db, appointment.Topic, queue.ID)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+		queue.Topic = appointment.Topic
+		appointment.Queue = &queue
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "addQueue",
+			"data": map[string]interface{}{
+				"queue":   queue,
+				"waiting": countWaitingAfterInProgress,
+			},
+		})
+		hub.broadcast <- message
+
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"appointment": appointment,
+			"queue":       queue,
+			"waiting":     countWaitingAfterInProgress,
+		})
+	}
+}
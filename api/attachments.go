@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"src/helpers"
+	"src/models"
+	"src/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxQueueAttachments = 5
+
+var (
+	errAttachmentLimit    = errors.New("attachment limit reached")
+	errQueueNotAttachable = errors.New("queue is no longer waiting")
+)
+
+var attachmentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+}
+
+func MaxAttachmentBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_MB"), 10, 64); err == nil && value > 0 {
+		return value * 1024 * 1024
+	}
+	return 10 * 1024 * 1024
+}
+
+func canAccessAttachments(c *gin.Context, db *gorm.DB, queue models.Queue) bool {
+	userClaims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return false
+	}
+	switch userClaims["role"] {
+	case helpers.ADMIN, helpers.COUNTER_STAFF:
+		return canServeQueue(c, db, queue)
+	}
+	if ownsQueue(userClaims, queue) {
+		return true
+	}
+	if _, err := claimedMember(c, db, queue.ID); err == nil {
+		return true
+	}
+	helpers.FormatErrorResponse(c, http.StatusForbidden, "This queue belongs to someone else")
+	return false
+}
+
+func loadAttachment(c *gin.Context, db *gorm.DB) (models.Attachment, models.Queue, bool) {
+	var attachment models.Attachment
+	var queue models.Queue
+	if err := db.First(&attachment, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Attachment not found")
+			return attachment, queue, false
+		}
+		helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve attachment")
+		return attachment, queue, false
+	}
+	if err := db.First(&queue, attachment.QueueID).Error; err != nil {
+		helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+		return attachment, queue, false
+	}
+	if !canAccessAttachments(c, db, queue) {
+		return attachment, queue, false
+	}
+	return attachment, queue, true
+}
+
+func storageKey(queueID int, extension string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("queues/%d/%s%s", queueID, hex.EncodeToString(random), extension), nil
+}
+
+func UploadAttachment(db *gorm.DB, hub *Hub, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxBytes := MaxAttachmentBytes()
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1024*1024)
+
+		queue, ok := findOwnQueue(c, db)
+		if !ok {
+			return
+		}
+		if queue.Status != helpers.WAITING {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Files can only be attached while the queue is waiting")
+			return
+		}
+		var count int64
+		if err := db.Model(&models.Attachment{}).Where("queue_id = ?", queue.ID).Count(&count).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count attachments")
+			return
+		}
+		if count >= maxQueueAttachments {
+			helpers.FormatErrorResponse(c, http.StatusConflict, fmt.Sprintf("A queue can have at most %d attachments", maxQueueAttachments))
+			return
+		}
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "A file is required")
+			return
+		}
+		if header.Size > maxBytes {
+			helpers.FormatErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Files must be at most %d MB", maxBytes/1024/1024))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+			return
+		}
+		defer file.Close()
+
+		sniff := make([]byte, 512)
+		n, err := io.ReadFull(file, sniff)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+			return
+		}
+		contentType := http.DetectContentType(sniff[:n])
+		extension, allowed := attachmentTypes[contentType]
+		if !allowed {
+			helpers.FormatErrorResponse(c, http.StatusUnsupportedMediaType, "Only PDF, JPEG, PNG and WebP files can be attached")
+			return
+		}
+
+		key, err := storageKey(queue.ID, extension)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to store file")
+			return
+		}
+		body := io.MultiReader(bytes.NewReader(sniff[:n]), file)
+		if err := store.Put(c.Request.Context(), key, body, header.Size, contentType); err != nil {
+			log.Printf("Error storing attachment for queue %d: %v", queue.ID, err)
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to store file")
+			return
+		}
+
+		attachment := models.Attachment{
+			QueueID:     queue.ID,
+			FileName:    filepath.Base(header.Filename),
+			ContentType: contentType,
+			Size:        header.Size,
+			StorageKey:  key,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var locked models.Queue
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, queue.ID).Error; err != nil {
+				return err
+			}
+			if locked.Status != helpers.WAITING {
+				return errQueueNotAttachable
+			}
+			var count int64
+			if err := tx.Model(&models.Attachment{}).Where("queue_id = ?", queue.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= maxQueueAttachments {
+				return errAttachmentLimit
+			}
+			return tx.Create(&attachment).Error
+		})
+		if err != nil {
+			if err := store.Delete(c.Request.Context(), key); err != nil {
+				log.Printf("Error removing orphaned attachment %s: %v", key, err)
+			}
+			switch {
+			case errors.Is(err, errQueueNotAttachable):
+				helpers.FormatErrorResponse(c, http.StatusConflict, "Files can only be attached while the queue is waiting")
+			case errors.Is(err, errAttachmentLimit):
+				helpers.FormatErrorResponse(c, http.StatusConflict, fmt.Sprintf("A queue can have at most %d attachments", maxQueueAttachments))
+			default:
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to save attachment")
+			}
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "addAttachment",
+			"data":  attachment,
+		})
+		hub.broadcast <- message
+
+		helpers.FormatSuccessResponse(c, attachment)
+	}
+}
+
+func GetQueueAttachments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var queue models.Queue
+		if err := db.First(&queue, c.Param("id")).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if !canAccessAttachments(c, db, queue) {
+			return
+		}
+
+		var attachments []models.Attachment
+		if err := db.Where("queue_id = ?", queue.ID).Order("created_at ASC, id ASC").Find(&attachments).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attachments")
+			return
+		}
+		helpers.FormatSuccessResponse(c, attachments)
+	}
+}
+
+func DownloadAttachment(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachment, _, ok := loadAttachment(c, db)
+		if !ok {
+			return
+		}
+
+		reader, err := store.Get(c.Request.Context(), attachment.StorageKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Attachment file not found")
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading attachment %d: %v", attachment.ID, err)
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to read attachment")
+			return
+		}
+		defer reader.Close()
+
+		c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+			"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+func DeleteAttachment(db *gorm.DB, hub *Hub, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attachment, queue, ok := loadAttachment(c, db)
+		if !ok {
+			return
+		}
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		if userClaims["role"] != helpers.ADMIN && userClaims["role"] != helpers.COUNTER_STAFF {
+			if !ownsQueue(userClaims, queue) {
+				helpers.FormatErrorResponse(c, http.StatusForbidden, "Only the ticket holder can remove attachments")
+				return
+			}
+			if queue.Status != helpers.WAITING {
+				helpers.FormatErrorResponse(c, http.StatusConflict, "Files can only be removed while the queue is waiting")
+				return
+			}
+		}
+
+		if err := db.Delete(&attachment).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to delete attachment")
+			return
+		}
+		if err := store.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", attachment.StorageKey, err)
+		}
+		recordAudit(db, c, "delete", "attachment", attachment.ID, attachment, nil)
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "deleteAttachment",
+			"data":  attachment,
+		})
+		hub.broadcast <- message
+
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Attachment deleted successfully"})
+	}
+}
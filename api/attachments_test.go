@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"src/helpers"
+	"src/models"
+	"src/storage"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func uploadRequest(t *testing.T, handler gin.HandlerFunc, queueID int, studentID string) int {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "scan.pdf")
+	if err != nil {
+		t.Errorf("failed to build form: %v", err)
+		return 0
+	}
+	part.Write([]byte("%PDF-1.4\n%test\n"))
+	writer.Close()
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/queue/attachments", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(queueID)}}
+	c.Set("claims", studentClaims(studentID))
+	handler(c)
+	c.Writer.WriteHeaderNow()
+	return recorder.Code
+}
+
+func TestUploadAttachmentLimitHoldsUnderConcurrency(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	topic := seedTopic(t, db, "A")
+	studentID := "650000001"
+	queue := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.WAITING, StudentID: &studentID})
+
+	const uploads = maxQueueAttachments + 4
+	var wg sync.WaitGroup
+	codes := make(chan int, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- uploadRequest(t, UploadAttachment(db, hub, store), queue.ID, studentID)
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created, rejected := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		case http.StatusConflict:
+			rejected++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	var stored int64
+	db.Model(&models.Attachment{}).Where("queue_id = ?", queue.ID).Count(&stored)
+	if created != maxQueueAttachments || stored != maxQueueAttachments || rejected != uploads-maxQueueAttachments {
+		t.Errorf("created %d, stored %d and rejected %d attachments, want %d, %d and %d",
+			created, stored, rejected, maxQueueAttachments, maxQueueAttachments, uploads-maxQueueAttachments)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"src/helpers"
+	"src/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func toAuditJSON(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+func auditDiff(before, after json.RawMessage) json.RawMessage {
+	var beforeMap, afterMap map[string]interface{}
+	json.Unmarshal(before, &beforeMap)
+	json.Unmarshal(after, &afterMap)
+	if beforeMap == nil && afterMap == nil {
+		return nil
+	}
+
+	diff := map[string]interface{}{}
+	for key, afterValue := range afterMap {
+		if beforeValue, ok := beforeMap[key]; !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[key] = map[string]interface{}{"before": beforeMap[key], "after": afterValue}
+		}
+	}
+	for key, beforeValue := range beforeMap {
+		if _, ok := afterMap[key]; !ok {
+			diff[key] = map[string]interface{}{"before": beforeValue, "after": nil}
+		}
+	}
+	return toAuditJSON(diff)
+}
+
+func recordAudit(db *gorm.DB, c *gin.Context, action, entityType string, entityID interface{}, before, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Before:     toAuditJSON(before),
+		After:      toAuditJSON(after),
+		IP:         c.ClientIP(),
+	}
+	entry.Diff = auditDiff(entry.Before, entry.After)
+
+	if claims, ok := c.Get("claims"); ok {
+		if userClaims, ok := claims.(jwt.MapClaims); ok {
+			if email, ok := userClaims["email"].(string); ok && email != "" {
+				entry.ActorEmail = &email
+			}
+			firstName, _ := userClaims["firstName"].(string)
+			lastName, _ := userClaims["lastName"].(string)
+			entry.ActorName = firstName + " " + lastName
+			entry.ActorRole, _ = userClaims["role"].(string)
+		}
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Error recording audit log for %s %s %v: %v", action, entityType, entityID, err)
+	}
+}
+
+func filterAuditLogs(c *gin.Context, db *gorm.DB) (*gorm.DB, bool) {
+	query := db.Model(&models.AuditLog{})
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_email ILIKE ?", "%"+actor+"%")
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entityType"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entityId"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if from := c.Query("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid from date, expected RFC3339")
+			return nil, false
+		}
+		query = query.Where("created_at >= ?", fromTime)
+	}
+	if to := c.Query("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid to date, expected RFC3339")
+			return nil, false
+		}
+		query = query.Where("created_at < ?", toTime)
+	}
+	return query, true
+}
+
+func GetAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid page")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid limit, must be between 1 and 100")
+			return
+		}
+
+		query, ok := filterAuditLogs(c, db)
+		if !ok {
+			return
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count audit logs")
+			return
+		}
+
+		var logs []models.AuditLog
+		if err := query.Order("created_at DESC, id DESC").
+			Offset((page - 1) * limit).Limit(limit).
+			Find(&logs).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit logs")
+			return
+		}
+
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"items": logs,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		})
+	}
+}
+
+func ExportAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, ok := filterAuditLogs(c, db)
+		if !ok {
+			return
+		}
+
+		var logs []models.AuditLog
+		if err := query.Order("created_at DESC, id DESC").Find(&logs).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit logs")
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-logs-%s.csv", helpers.GetBangkokTime().Format("20060102-150405")))
+		c.Status(http.StatusOK)
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"id", "createdAt", "actorEmail", "actorName", "actorRole", "action", "entityType", "entityId", "ip", "diff"})
+		for _, entry := range logs {
+			actorEmail := ""
+			if entry.ActorEmail != nil {
+				actorEmail = *entry.ActorEmail
+			}
+			writer.Write([]string{
+				strconv.Itoa(entry.ID),
+				entry.CreatedAt.Format(time.RFC3339),
+				actorEmail,
+				entry.ActorName,
+				entry.ActorRole,
+				entry.Action,
+				entry.EntityType,
+				entry.EntityID,
+				entry.IP,
+				string(entry.Diff),
+			})
+		}
+		writer.Flush()
+	}
+}
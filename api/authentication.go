@@ -70,16 +70,10 @@ type CmuEntraIDBasicInfoDTO struct {
 	ItAccountTypeEN    string `json:"itaccounttype_EN"`
 }
 
-func getEntraIDAccessToken(code, redirectUri string) (string, error) {
-	data := url.Values{}
-	data.Set("code", code)
-	data.Set("redirect_uri", redirectUri)
-	data.Set("client_id", os.Getenv("CMU_ENTRAID_CLIENT_ID"))
-	data.Set("client_secret", os.Getenv("CMU_ENTRAID_CLIENT_SECRET"))
-	data.Set("scope", os.Getenv("SCOPE"))
+func exchangeAuthorizationCode(tokenURL string, data url.Values) (string, error) {
 	data.Set("grant_type", "authorization_code")
 
-	req, err := http.NewRequest("POST", os.Getenv("CMU_ENTRAID_GET_TOKEN_URL"), bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -111,6 +105,16 @@ func getEntraIDAccessToken(code, redirectUri string) (string, error) {
 	return token, nil
 }
 
+func getEntraIDAccessToken(code, redirectUri string) (string, error) {
+	data := url.Values{}
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectUri)
+	data.Set("client_id", os.Getenv("CMU_ENTRAID_CLIENT_ID"))
+	data.Set("client_secret", os.Getenv("CMU_ENTRAID_CLIENT_SECRET"))
+	data.Set("scope", os.Getenv("SCOPE"))
+	return exchangeAuthorizationCode(os.Getenv("CMU_ENTRAID_GET_TOKEN_URL"), data)
+}
+
 func getCMUBasicInfo(accessToken string) (*CmuEntraIDBasicInfoDTO, error) {
 	client := &http.Client{}
 	url := os.Getenv("CMU_ENTRAID_GET_BASIC_INFO")
@@ -138,106 +142,112 @@ func getCMUBasicInfo(accessToken string) (*CmuEntraIDBasicInfoDTO, error) {
 	return &info, nil
 }
 
-func generateJWTToken(user interface{}, notAdmin bool) (string, error) {
+func generateJWTToken(db *gorm.DB, user interface{}, role string, counterID *int, userID *int) (map[string]interface{}, error) {
 	var firstName, lastName string
 	claims := jwt.MapClaims{}
 	switch v := user.(type) {
-	case CmuEntraIDBasicInfoDTO:
-		claims["email"] = v.CmuitAccount
-		firstName = v.FirstnameTH
-		lastName = v.LastnameTH
+	case Identity:
+		claims["email"] = v.Email
+		firstName = v.FirstNameTH
+		lastName = v.LastNameTH
 		if v.StudentID != "" {
 			claims["studentId"] = v.StudentID
 		}
-		if notAdmin {
-			claims["role"] = helpers.STUDENT
-		} else {
-			claims["role"] = helpers.ADMIN
-		}
 		if firstName == "" {
-			firstName = helpers.Capitalize(v.FirstnameEN)
+			firstName = helpers.Capitalize(v.FirstNameEN)
 		}
 		if lastName == "" {
-			lastName = helpers.Capitalize(v.LastnameEN)
+			lastName = helpers.Capitalize(v.LastNameEN)
+		}
+		claims["faculty"] = v.Faculty
+		if priority, ok := activePriorityGrant(db, v.Email); ok {
+			claims["priority"] = priority
 		}
-		claims["faculty"] = v.OrganizationNameTH
 	case ReserveDTO:
 		firstName = *v.FirstName
 		lastName = *v.LastName
-		claims["role"] = helpers.STUDENT
+	}
+	claims["role"] = role
+	if counterID != nil {
+		claims["counterId"] = *counterID
 	}
 	claims["firstName"] = firstName
 	claims["lastName"] = lastName
 
-	// expirationTime := time.Now().Add(7 * 24 * time.Hour)
-	// claims["exp"] = expirationTime.Unix()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return issueTokens(db, claims, userID)
+}
 
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	tokenString, err := token.SignedString([]byte(secretKey))
-	if err != nil {
-		return "", err
+func isStaffAccount(user models.User) bool {
+	switch user.Role {
+	case helpers.ADMIN, helpers.VIEWER:
+		return true
+	case helpers.COUNTER_STAFF:
+		return user.CounterID != nil
 	}
-
-	return tokenString, nil
+	return false
 }
 
-func Authentication(db *gorm.DB) gin.HandlerFunc {
+func Authentication(db *gorm.DB, provider IdentityProvider) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var body AuthDTO
 		if err := c.Bind(&body); err != nil || body.Code == "" || body.RedirectURI == "" {
 			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid authorization code or redirect URI")
 			return
 		}
-		accessToken, err := getEntraIDAccessToken(body.Code, body.RedirectURI)
+		accessToken, err := provider.ExchangeCode(body.Code, body.RedirectURI)
 		if err != nil || accessToken == "" {
-			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Cannot get EntraID access token")
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Cannot get %s access token", provider.Name()))
 			return
 		}
-		basicInfo, err := getCMUBasicInfo(accessToken)
-		if err != nil || basicInfo == nil {
-			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Cannot get CMU basic info")
+		identity, err := provider.FetchProfile(accessToken)
+		if err != nil || identity == nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Cannot get %s profile", provider.Name()))
 			return
 		}
 
 		var user models.User
-		result := db.Where("email = ? AND counter_id IS NOT NULL", basicInfo.CmuitAccount).First(&user)
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			if basicInfo.ItAccountTypeID == STUDENT.String() {
-				tokenString, err := generateJWTToken(*basicInfo, true)
-				if err != nil {
-					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate JWT token")
-					return
-				}
-				helpers.FormatSuccessResponse(c, map[string]interface{}{"token": tokenString})
-				return
-			} else {
+		result := db.Where("email = ?", identity.Email).First(&user)
+		if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve user")
+			return
+		}
+		if result.Error == nil && user.Disabled {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "Account is disabled")
+			return
+		}
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) || !isStaffAccount(user) {
+			role := provider.MapRole(identity)
+			if role == "" {
 				helpers.FormatErrorResponse(c, http.StatusForbidden, "Cannot access")
 				return
 			}
+			tokens, err := generateJWTToken(db, *identity, role, nil, nil)
+			if err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate JWT token")
+				return
+			}
+			helpers.FormatSuccessResponse(c, tokens)
+			return
 		}
 
-		tokenString, err := generateJWTToken(*basicInfo, false)
+		tokens, err := generateJWTToken(db, *identity, user.Role, user.CounterID, &user.ID)
 		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate JWT token")
 			return
 		}
 
 		if user.FirstNameEN == nil || user.LastNameEN == nil {
-			user.FirstNameTH = &basicInfo.FirstnameTH
-			user.LastNameTH = &basicInfo.LastnameTH
-			user.FirstNameEN = &basicInfo.FirstnameEN
-			user.LastNameEN = &basicInfo.LastnameEN
+			user.FirstNameTH = &identity.FirstNameTH
+			user.LastNameTH = &identity.LastNameTH
+			user.FirstNameEN = &identity.FirstNameEN
+			user.LastNameEN = &identity.LastNameEN
 			if err := db.Save(&user).Error; err != nil {
 				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update user data")
 				return
 			}
 		}
 
-		helpers.FormatSuccessResponse(c, map[string]interface{}{
-			"token": tokenString,
-			"user":  user,
-		})
+		tokens["user"] = user
+		helpers.FormatSuccessResponse(c, tokens)
 	}
 }
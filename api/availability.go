@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type topicAvailability struct {
+	Status          helpers.AVAILABILITY `json:"status"`
+	Reason          *helpers.ERROR_CODE  `json:"reason"`
+	Message         string               `json:"message,omitempty"`
+	IssuedToday     int                  `json:"issuedToday"`
+	DailyCap        int                  `json:"dailyCap"`
+	ProjectedFinish *time.Time           `json:"projectedFinish"`
+}
+
+type topicUnavailableError struct {
+	Availability topicAvailability
+}
+
+func (e *topicUnavailableError) Error() string {
+	return e.Availability.Message
+}
+
+func validIssuancePolicy(topic models.Topic) error {
+	day, _ := helpers.GetStartAndEndOfDay()
+	var opens, closes time.Time
+	var err error
+	if topic.IssueOpensAt != nil {
+		if opens, err = parseClock(*topic.IssueOpensAt, day); err != nil {
+			return fmt.Errorf("Invalid issueOpensAt '%v', expected HH:MM", *topic.IssueOpensAt)
+		}
+	}
+	if topic.IssueClosesAt != nil {
+		if closes, err = parseClock(*topic.IssueClosesAt, day); err != nil {
+			return fmt.Errorf("Invalid issueClosesAt '%v', expected HH:MM", *topic.IssueClosesAt)
+		}
+	}
+	if topic.IssueOpensAt != nil && topic.IssueClosesAt != nil && !closes.After(opens) {
+		return fmt.Errorf("issueClosesAt must be after issueOpensAt")
+	}
+	if topic.DailyCap < 0 {
+		return fmt.Errorf("dailyCap must not be negative")
+	}
+	return nil
+}
+
+func unavailable(availability topicAvailability, status helpers.AVAILABILITY, reason helpers.ERROR_CODE, message string) topicAvailability {
+	availability.Status = status
+	availability.Reason = &reason
+	availability.Message = message
+	return availability
+}
+
+func latestClosingTime(db *gorm.DB, topicID int, day time.Time) (*time.Time, error) {
+	var closings []string
+	if err := db.Model(&models.Counter{}).
+		Joins("JOIN counter_topics ON counter_topics.counter_id = counters.id").
+		Where("counter_topics.topic_id = ?", topicID).
+		Pluck("counters.time_closed", &closings).Error; err != nil {
+		return nil, err
+	}
+	var latest *time.Time
+	for _, closing := range closings {
+		closed, err := parseClock(closing, day)
+		if err != nil {
+			continue
+		}
+		if latest == nil || closed.After(*latest) {
+			latest = &closed
+		}
+	}
+	return latest, nil
+}
+
+func TopicAvailability(db *gorm.DB, topic models.Topic, waiting int) (topicAvailability, error) {
+	availability := topicAvailability{Status: helpers.AVAILABILITY_OPEN, DailyCap: topic.DailyCap}
+	now := helpers.GetBangkokTime()
+	startOfDay, _ := helpers.GetStartAndEndOfDay()
+
+	if topic.IssueOpensAt != nil {
+		if opens, err := parseClock(*topic.IssueOpensAt, startOfDay); err == nil && now.Before(opens) {
+			return unavailable(availability, helpers.AVAILABILITY_CLOSED, helpers.TOPIC_NOT_OPEN_YET,
+				fmt.Sprintf("Tickets for this topic are issued from %s", opens.Format("15:04"))), nil
+		}
+	}
+	if topic.IssueClosesAt != nil {
+		if closes, err := parseClock(*topic.IssueClosesAt, startOfDay); err == nil && !now.Before(closes) {
+			return unavailable(availability, helpers.AVAILABILITY_CLOSED, helpers.TOPIC_ISSUANCE_CLOSED,
+				fmt.Sprintf("Tickets for this topic are no longer issued after %s", closes.Format("15:04"))), nil
+		}
+	}
+
+	var issued int64
+	if err := db.Model(&models.Queue{}).
+		Where("topic_id = ? AND service_date = ?", topic.ID, startOfDay).
+		Count(&issued).Error; err != nil {
+		return availability, err
+	}
+	availability.IssuedToday = int(issued)
+	if topic.DailyCap > 0 && availability.IssuedToday >= topic.DailyCap {
+		return unavailable(availability, helpers.AVAILABILITY_FULL, helpers.TOPIC_DAILY_CAP_REACHED,
+			"Today's tickets for this topic have run out"), nil
+	}
+
+	if !topic.CutoffAtClosing {
+		return availability, nil
+	}
+	estimate, err := EstimateTopicEta(db, topic.ID, waiting)
+	if err != nil {
+		return availability, err
+	}
+	if estimate.EtaSeconds == nil {
+		return availability, nil
+	}
+	latest, err := latestClosingTime(db, topic.ID, startOfDay)
+	if err != nil {
+		return availability, err
+	}
+	finish := now.Add(time.Duration(*estimate.EtaSeconds+estimate.AverageServiceSeconds) * time.Second)
+	availability.ProjectedFinish = &finish
+	if latest != nil && finish.After(*latest) {
+		return unavailable(availability, helpers.AVAILABILITY_CLOSED, helpers.TOPIC_PAST_CLOSING,
+			"The office cannot serve another ticket for this topic before closing"), nil
+	}
+	return availability, nil
+}
+
+func checkTopicAvailability(tx *gorm.DB, topic models.Topic) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("topic:%d", topic.ID)).Error; err != nil {
+		return err
+	}
+	var waiting int64
+	if err := tx.Model(&models.Queue{}).
+		Where("topic_id = ? AND status IN ?", topic.ID, []helpers.STATUS{helpers.WAITING, helpers.CALLING, helpers.SERVING}).
+		Count(&waiting).Error; err != nil {
+		return err
+	}
+	availability, err := TopicAvailability(tx, topic, int(waiting))
+	if err != nil {
+		return err
+	}
+	if availability.Status != helpers.AVAILABILITY_OPEN {
+		return &topicUnavailableError{Availability: availability}
+	}
+	return nil
+}
+
+func topicUnavailableErrorResponse(c *gin.Context, err error) bool {
+	var unavailableErr *topicUnavailableError
+	if !errors.As(err, &unavailableErr) {
+		return false
+	}
+	helpers.FormatErrorResponse(c, http.StatusConflict, map[string]interface{}{
+		"code":         unavailableErr.Availability.Reason,
+		"message":      unavailableErr.Availability.Message,
+		"availability": unavailableErr.Availability,
+	})
+	return true
+}
@@ -2,14 +2,33 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"src/helpers"
 	"src/models"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
+func loadConfig(db *gorm.DB) (models.Config, error) {
+	config := models.Config{
+		LoginNotCmu:           true,
+		Audio:                 "th",
+		PriorityAgingMinutes:  15,
+		RoutingStrategy:       helpers.ROUTING_PRIORITY_FIRST,
+		MaxActiveTickets:      1,
+		OneTicketPerTopic:     true,
+		NoShowCooldownMinutes: 30,
+	}
+	if err := db.First(&config).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return config, err
+	}
+	return config, nil
+}
+
 func GetConfig(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var config models.Config
@@ -35,6 +54,14 @@ func SetLoginNotCmu(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			return
 		}
 
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		after.LoginNotCmu = body.LoginNotCmu
+
 		if err := db.Model(&models.Config{}).Where("id = ?", 1).Update("login_not_cmu", body.LoginNotCmu).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
 			return
@@ -46,10 +73,175 @@ func SetLoginNotCmu(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "update", "config", before.ID, before, after)
 		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "LoginNotCmu updated successfully"})
 	}
 }
 
+func SetTermStarts(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			TermStarts []string `json:"termStarts"`
+		})
+		if err := c.ShouldBindJSON(&body); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		for _, termStart := range body.TermStarts {
+			if _, err := time.Parse("2006-01-02", termStart); err != nil {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid term start '%v', expected YYYY-MM-DD", termStart))
+				return
+			}
+		}
+
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		after.TermStarts = pq.StringArray(body.TermStarts)
+
+		if err := db.Model(&models.Config{}).Where("id = ?", 1).Update("term_starts", after.TermStarts).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "setTermStarts",
+			"data":  body.TermStarts,
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "update", "config", before.ID, before, after)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Term starts updated successfully"})
+	}
+}
+
+func SetPriorityAging(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			PriorityAgingMinutes *int `json:"priorityAgingMinutes"`
+		})
+		if err := c.ShouldBindJSON(&body); err != nil || body.PriorityAgingMinutes == nil || *body.PriorityAgingMinutes < 0 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		after.PriorityAgingMinutes = *body.PriorityAgingMinutes
+
+		if err := db.Model(&models.Config{}).Where("id = ?", 1).Update("priority_aging_minutes", after.PriorityAgingMinutes).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "setPriorityAging",
+			"data":  after.PriorityAgingMinutes,
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "update", "config", before.ID, before, after)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Priority aging updated successfully"})
+	}
+}
+
+func SetRoutingStrategy(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			RoutingStrategy helpers.ROUTING_STRATEGY `json:"routingStrategy"`
+		})
+		if err := c.ShouldBindJSON(&body); err != nil || !validRoutingStrategy(body.RoutingStrategy) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid routingStrategy")
+			return
+		}
+
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		after.RoutingStrategy = body.RoutingStrategy
+
+		if err := db.Model(&models.Config{}).Where("id = ?", 1).Update("routing_strategy", after.RoutingStrategy).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "setRoutingStrategy",
+			"data":  after.RoutingStrategy,
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "update", "config", before.ID, before, after)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Routing strategy updated successfully"})
+	}
+}
+
+func SetTicketLimits(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			MaxActiveTickets      *int  `json:"maxActiveTickets"`
+			OneTicketPerTopic     *bool `json:"oneTicketPerTopic"`
+			NoShowCooldownMinutes *int  `json:"noShowCooldownMinutes"`
+		})
+		if err := c.ShouldBindJSON(&body); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if (body.MaxActiveTickets != nil && *body.MaxActiveTickets < 0) || (body.NoShowCooldownMinutes != nil && *body.NoShowCooldownMinutes < 0) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "maxActiveTickets and noShowCooldownMinutes must not be negative")
+			return
+		}
+
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		if body.MaxActiveTickets != nil {
+			after.MaxActiveTickets = *body.MaxActiveTickets
+		}
+		if body.OneTicketPerTopic != nil {
+			after.OneTicketPerTopic = *body.OneTicketPerTopic
+		}
+		if body.NoShowCooldownMinutes != nil {
+			after.NoShowCooldownMinutes = *body.NoShowCooldownMinutes
+		}
+
+		if err := db.Model(&models.Config{}).Where("id = ?", 1).Updates(map[string]interface{}{
+			"max_active_tickets":       after.MaxActiveTickets,
+			"one_ticket_per_topic":     after.OneTicketPerTopic,
+			"no_show_cooldown_minutes": after.NoShowCooldownMinutes,
+		}).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "setTicketLimits",
+			"data": map[string]interface{}{
+				"maxActiveTickets":      after.MaxActiveTickets,
+				"oneTicketPerTopic":     after.OneTicketPerTopic,
+				"noShowCooldownMinutes": after.NoShowCooldownMinutes,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "update", "config", before.ID, before, after)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Ticket limits updated successfully"})
+	}
+}
+
 func SetAudio(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		body := new(struct {
@@ -60,6 +252,14 @@ func SetAudio(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			return
 		}
 
+		before, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		after := before
+		after.Audio = body.Audio
+
 		if err := db.Model(&models.Config{}).Where("id = ?", 1).Update("audio", body.Audio).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update config")
 			return
@@ -71,6 +271,7 @@ func SetAudio(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "update", "config", before.ID, before, after)
 		helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Audio updated successfully"})
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"src/helpers"
+	"src/middleware"
 	"src/models"
 	"strconv"
 
@@ -28,7 +29,7 @@ func GetCounters(db *gorm.DB) gin.HandlerFunc {
 		var response []models.CounterResponse
 		for _, counter := range counters {
 			var currentQueue *models.Queue
-			err := db.Where("status != ? AND counter_id = ? AND created_at >= ? AND created_at < ?", helpers.WAITING, counter.ID, startOfDay, endOfDay).
+			err := db.Where("status IN ? AND counter_id = ? AND created_at >= ? AND created_at < ?", []helpers.STATUS{helpers.CALLING, helpers.SERVING, helpers.DONE}, counter.ID, startOfDay, endOfDay).
 				Order("created_at DESC, no DESC").First(&currentQueue).Error
 			if err != nil {
 				if err == gorm.ErrRecordNotFound {
@@ -43,6 +44,7 @@ func GetCounters(db *gorm.DB) gin.HandlerFunc {
 				ID:         counter.ID,
 				Counter:    counter.Counter,
 				Status:     counter.Status,
+				TimeOpened: counter.TimeOpened,
 				TimeClosed: counter.TimeClosed,
 				User: models.UserWithoutCounter{
 					ID:          counter.User.ID,
@@ -65,6 +67,7 @@ func CreateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		body := new(struct {
 			Counter    string `json:"counter"`
 			Email      string `json:"email"`
+			TimeOpened string `json:"timeOpened"`
 			TimeClosed string `json:"timeClosed"`
 			Topics     []int  `json:"topics"`
 		})
@@ -98,6 +101,7 @@ func CreateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		}
 		counter = models.Counter{
 			Counter:    body.Counter,
+			TimeOpened: body.TimeOpened,
 			TimeClosed: body.TimeClosed,
 		}
 		err = tx.Create(&counter).Error
@@ -126,6 +130,13 @@ func CreateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 				return
 			}
 		} else {
+			if user.CounterID != nil {
+				if err := revokeUserSessions(tx, user.ID); err != nil {
+					tx.Rollback()
+					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions")
+					return
+				}
+			}
 			user.CounterID = &counter.ID
 			err = tx.Save(&user).Error
 			if err != nil {
@@ -165,6 +176,7 @@ func CreateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "create", "counter", result.ID, nil, result)
 		helpers.FormatSuccessResponse(c, result)
 	}
 }
@@ -180,6 +192,7 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		body := new(struct {
 			Counter    *string `json:"counter"`
 			Status     *bool   `json:"status"`
+			TimeOpened *string `json:"timeOpened"`
 			TimeClosed *string `json:"timeClosed"`
 			Email      *string `json:"email"`
 			Topics     *[]int  `json:"topics"`
@@ -188,6 +201,17 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
+		if !middleware.CanAccessCounter(c, id) {
+			return
+		}
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		if userClaims["role"] != helpers.ADMIN && (body.Counter != nil || body.Email != nil || body.Topics != nil) {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "Only admins can change the counter name, staff or topics")
+			return
+		}
 
 		tx := db.Begin()
 		if tx.Error != nil {
@@ -201,12 +225,15 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		}()
 
 		var counter models.Counter
-		err = tx.First(&counter, id).Error
+		err = tx.Preload("User").Preload("Topics").First(&counter, id).Error
 		if err != nil {
 			tx.Rollback()
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Counter not found")
 			return
 		}
+		before := counter
+		counter.User = nil
+		counter.Topics = nil
 
 		if body.Counter != nil {
 			counter.Counter = *body.Counter
@@ -214,6 +241,9 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		if body.Status != nil {
 			counter.Status = *body.Status
 		}
+		if body.TimeOpened != nil {
+			counter.TimeOpened = *body.TimeOpened
+		}
 		if body.TimeClosed != nil {
 			counter.TimeClosed = *body.TimeClosed
 		}
@@ -228,20 +258,19 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		if body.Status != nil && !*body.Status {
 			var queue models.Queue
 			err = tx.Model(&models.Queue{}).
-				Where("counter_id = ? AND status = ?", counter.ID, helpers.IN_PROGRESS).
+				Where("counter_id = ? AND status IN ?", counter.ID, []helpers.STATUS{helpers.CALLING, helpers.SERVING}).
 				First(&queue).Error
 			if err != nil && err != gorm.ErrRecordNotFound {
 				tx.Rollback()
 				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch queue")
 				return
 			}
-			err = tx.Model(&models.Queue{}).
-				Where("id = ?", queue.ID).
-				Update("status", helpers.CALLED).Error
-			if err != nil {
-				tx.Rollback()
-				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update queue status")
-				return
+			if err == nil {
+				if err := FinishQueue(tx, &queue, queueActor(c, tx)); err != nil {
+					tx.Rollback()
+					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update queue status")
+					return
+				}
 			}
 
 			message, _ := json.Marshal(map[string]interface{}{
@@ -289,6 +318,11 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to detach previous user from counter")
 					return
 				}
+				if err := revokeUserSessions(tx, existingUser.ID); err != nil {
+					tx.Rollback()
+					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke previous user sessions")
+					return
+				}
 			}
 			var user models.User
 			err = tx.Where("email = ?", *body.Email).First(&user).Error
@@ -309,6 +343,13 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 					return
 				}
 			} else {
+				if user.CounterID != nil && *user.CounterID != counter.ID {
+					if err := revokeUserSessions(tx, user.ID); err != nil {
+						tx.Rollback()
+						helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions")
+						return
+					}
+				}
 				user.CounterID = &counter.ID
 				err = tx.Save(&user).Error
 				if err != nil {
@@ -340,6 +381,7 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "update", "counter", updatedCounter.ID, before, updatedCounter)
 		helpers.FormatSuccessResponse(c, updatedCounter)
 	}
 }
@@ -347,7 +389,23 @@ func UpdateCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 func DeleteCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
+		var counter models.Counter
+		if err := db.Preload("User").Preload("Topics").First(&counter, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Counter not found")
+			return
+		}
 		tx := db.Begin()
+		var userIDs []int
+		if err := tx.Model(&models.User{}).Where("counter_id = ?", id).Pluck("id", &userIDs).Error; err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch associated users")
+			return
+		}
+		if err := revokeUserSessions(tx, userIDs...); err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke associated user sessions")
+			return
+		}
 		if err := tx.Model(&models.User{}).Where("counter_id = ?", id).Update("counter_id", nil).Error; err != nil {
 			tx.Rollback()
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update associated users")
@@ -366,6 +424,7 @@ func DeleteCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "delete", "counter", counter.ID, counter, nil)
 		helpers.FormatSuccessResponse(c, map[string]string{"message": "Counter deleted successfully"})
 	}
 }
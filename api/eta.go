@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"src/helpers"
+	"src/models"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultServiceSeconds = 300
+	etaSampleSize         = 20
+	etaSampleDays         = 14
+	etaChangeSeconds      = 120
+	etaChangeRatio        = 0.2
+)
+
+type TopicEta struct {
+	TopicID               int      `json:"topicId"`
+	Waiting               int      `json:"waiting"`
+	OpenCounters          int      `json:"openCounters"`
+	AverageServiceSeconds float64  `json:"averageServiceSeconds"`
+	EtaSeconds            *float64 `json:"etaSeconds"`
+}
+
+var lastTopicEtas = struct {
+	sync.Mutex
+	etas map[int]*float64
+}{etas: map[int]*float64{}}
+
+func averageServiceSeconds(db *gorm.DB, topicID int) (float64, error) {
+	var average *float64
+	err := db.Raw(`
+		SELECT AVG(EXTRACT(EPOCH FROM (recent.finished_at - recent.called_at))) FROM (
+			SELECT finished_at, called_at FROM queues
+			WHERE topic_id = ? AND status = ? AND called_at IS NOT NULL AND finished_at IS NOT NULL
+			AND finished_at > now() - ? * INTERVAL '1 day'
+			ORDER BY finished_at DESC
+			LIMIT ?
+		) recent
+	`, topicID, helpers.DONE, etaSampleDays, etaSampleSize).Scan(&average).Error
+	if err != nil {
+		return 0, err
+	}
+	if average == nil || *average <= 0 {
+		return defaultServiceSeconds, nil
+	}
+	return *average, nil
+}
+
+func openCounters(db *gorm.DB, topicID int) (int, error) {
+	var count int64
+	err := db.Model(&models.Counter{}).
+		Joins("JOIN counter_topics ON counter_topics.counter_id = counters.id").
+		Where("counter_topics.topic_id = ? AND counters.status = ?", topicID, true).
+		Count(&count).Error
+	return int(count), err
+}
+
+func averageServiceSecondsByTopic(db *gorm.DB) (map[int]float64, error) {
+	var rows []struct {
+		TopicID int
+		Average float64
+	}
+	err := db.Raw(`
+		SELECT recent.topic_id, AVG(EXTRACT(EPOCH FROM (recent.finished_at - recent.called_at))) AS average FROM (
+			SELECT topic_id, finished_at, called_at,
+				ROW_NUMBER() OVER (PARTITION BY topic_id ORDER BY finished_at DESC) AS recency
+			FROM queues
+			WHERE status = ? AND called_at IS NOT NULL AND finished_at IS NOT NULL
+			AND finished_at > now() - ? * INTERVAL '1 day'
+		) recent
+		WHERE recent.recency <= ?
+		GROUP BY recent.topic_id
+	`, helpers.DONE, etaSampleDays, etaSampleSize).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	averages := map[int]float64{}
+	for _, row := range rows {
+		if row.Average > 0 {
+			averages[row.TopicID] = row.Average
+		}
+	}
+	return averages, nil
+}
+
+func openCountersByTopic(db *gorm.DB) (map[int]int, error) {
+	var rows []struct {
+		TopicID int
+		Count   int
+	}
+	err := db.Table("counter_topics").
+		Select("counter_topics.topic_id, COUNT(*) AS count").
+		Joins("JOIN counters ON counters.id = counter_topics.counter_id").
+		Where("counters.status = ?", true).
+		Group("counter_topics.topic_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counters := map[int]int{}
+	for _, row := range rows {
+		counters[row.TopicID] = row.Count
+	}
+	return counters, nil
+}
+
+func estimateWaitSeconds(ahead, counters int, average float64) *float64 {
+	if counters == 0 {
+		return nil
+	}
+	eta := math.Round(float64(ahead) * average / float64(counters))
+	return &eta
+}
+
+func EstimateTopicEta(db *gorm.DB, topicID, ahead int) (TopicEta, error) {
+	average, err := averageServiceSeconds(db, topicID)
+	if err != nil {
+		return TopicEta{TopicID: topicID, Waiting: ahead}, fmt.Errorf("failed to average service time: %v", err)
+	}
+	counters, err := openCounters(db, topicID)
+	if err != nil {
+		return TopicEta{TopicID: topicID, Waiting: ahead}, fmt.Errorf("failed to count open counters: %v", err)
+	}
+	return newTopicEta(topicID, ahead, counters, average), nil
+}
+
+func EstimateTopicEtas(db *gorm.DB, waiting map[int]int) (map[int]TopicEta, error) {
+	averages, err := averageServiceSecondsByTopic(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to average service time: %v", err)
+	}
+	counters, err := openCountersByTopic(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count open counters: %v", err)
+	}
+	estimates := map[int]TopicEta{}
+	for topicID, ahead := range waiting {
+		average, ok := averages[topicID]
+		if !ok {
+			average = defaultServiceSeconds
+		}
+		estimates[topicID] = newTopicEta(topicID, ahead, counters[topicID], average)
+	}
+	return estimates, nil
+}
+
+func newTopicEta(topicID, ahead, counters int, average float64) TopicEta {
+	return TopicEta{
+		TopicID:               topicID,
+		Waiting:               ahead,
+		OpenCounters:          counters,
+		AverageServiceSeconds: math.Round(average),
+		EtaSeconds:            estimateWaitSeconds(ahead, counters, average),
+	}
+}
+
+func EstimateQueueEta(db *gorm.DB, queue models.Queue, ahead int) (*float64, error) {
+	if queue.Status != helpers.WAITING {
+		return nil, nil
+	}
+	estimate, err := EstimateTopicEta(db, queue.TopicID, ahead)
+	if err != nil {
+		return nil, err
+	}
+	return estimate.EtaSeconds, nil
+}
+
+func etaChanged(previous, current *float64) bool {
+	if previous == nil || current == nil {
+		return previous != current
+	}
+	diff := math.Abs(*current - *previous)
+	return diff >= etaChangeSeconds || (diff > 0 && diff >= *previous*etaChangeRatio)
+}
+
+func BroadcastEtaUpdates(db *gorm.DB, hub *Hub) error {
+	var rows []struct {
+		ID      int
+		Waiting int
+	}
+	if err := db.Table("topics").
+		Select("topics.id, COUNT(queues.id) AS waiting").
+		Joins("LEFT JOIN queues ON queues.topic_id = topics.id AND queues.status IN ?", []helpers.STATUS{helpers.WAITING, helpers.CALLING, helpers.SERVING}).
+		Group("topics.id").
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to count waiting queues: %v", err)
+	}
+
+	waiting := map[int]int{}
+	for _, row := range rows {
+		waiting[row.ID] = row.Waiting
+	}
+	estimates, err := EstimateTopicEtas(db, waiting)
+	if err != nil {
+		return err
+	}
+
+	var changed []TopicEta
+	lastTopicEtas.Lock()
+	for _, row := range rows {
+		estimate := estimates[row.ID]
+		previous, seen := lastTopicEtas.etas[row.ID]
+		if !seen || etaChanged(previous, estimate.EtaSeconds) {
+			lastTopicEtas.etas[row.ID] = estimate.EtaSeconds
+			changed = append(changed, estimate)
+		}
+	}
+	lastTopicEtas.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	message, _ := json.Marshal(map[string]interface{}{
+		"event": "updateEta",
+		"data":  changed,
+	})
+	hub.Broadcast(message)
+	return nil
+}
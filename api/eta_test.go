@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"src/helpers"
+	"src/models"
+	"testing"
+	"time"
+)
+
+func TestEstimateTopicEtasMatchesSingleTopicEstimate(t *testing.T) {
+	db := openTestDB(t)
+	busy := seedTopic(t, db, "A")
+	quiet := seedTopic(t, db, "B")
+	closed := seedTopic(t, db, "C")
+	seedCounter(t, db, "1", busy, quiet)
+	seedCounter(t, db, "2", busy)
+
+	now := time.Now()
+	for i, minutes := range []int{4, 6, 5} {
+		called := now.Add(-time.Duration(30+i) * time.Minute)
+		finished := called.Add(time.Duration(minutes) * time.Minute)
+		seedQueue(t, db, models.Queue{No: fmt.Sprintf("A%03d", i+1), TopicID: busy.ID, Status: helpers.DONE, CalledAt: &called, FinishedAt: &finished})
+	}
+
+	waiting := map[int]int{busy.ID: 6, quiet.ID: 2, closed.ID: 1}
+	estimates, err := EstimateTopicEtas(db, waiting)
+	if err != nil {
+		t.Fatalf("EstimateTopicEtas: %v", err)
+	}
+	for topicID, ahead := range waiting {
+		single, err := EstimateTopicEta(db, topicID, ahead)
+		if err != nil {
+			t.Fatalf("EstimateTopicEta(%d): %v", topicID, err)
+		}
+		batched := estimates[topicID]
+		if batched.OpenCounters != single.OpenCounters || batched.AverageServiceSeconds != single.AverageServiceSeconds {
+			t.Errorf("topic %d: batched %+v, single %+v", topicID, batched, single)
+		}
+		if (batched.EtaSeconds == nil) != (single.EtaSeconds == nil) ||
+			(batched.EtaSeconds != nil && *batched.EtaSeconds != *single.EtaSeconds) {
+			t.Errorf("topic %d: batched eta %v, single eta %v", topicID, batched.EtaSeconds, single.EtaSeconds)
+		}
+	}
+	if estimates[closed.ID].EtaSeconds != nil {
+		t.Errorf("topic without open counters has eta %v, want nil", *estimates[closed.ID].EtaSeconds)
+	}
+}
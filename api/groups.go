@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"src/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+const maxGroupMembers = 10
+
+type QueueMemberDTO struct {
+	StudentID *string `json:"studentId"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+}
+
+func buildQueueMembers(holder models.Queue, members []QueueMemberDTO) ([]models.QueueMember, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+	if len(members) >= maxGroupMembers {
+		return nil, fmt.Errorf("A group ticket can have at most %d members", maxGroupMembers)
+	}
+
+	result := []models.QueueMember{{
+		StudentID: holder.StudentID,
+		Firstname: holder.Firstname,
+		Lastname:  holder.Lastname,
+	}}
+	seen := map[string]bool{strings.ToLower(holder.Firstname + " " + holder.Lastname): true}
+	for _, member := range members {
+		firstName := strings.TrimSpace(member.FirstName)
+		lastName := strings.TrimSpace(member.LastName)
+		if firstName == "" || lastName == "" {
+			return nil, fmt.Errorf("Every group member needs a firstName and lastName")
+		}
+		if member.StudentID != nil && (*member.StudentID == "" || len(*member.StudentID) > 9) {
+			return nil, fmt.Errorf("Invalid studentId for %s %s", firstName, lastName)
+		}
+		key := strings.ToLower(firstName + " " + lastName)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, models.QueueMember{
+			StudentID: member.StudentID,
+			Firstname: firstName,
+			Lastname:  lastName,
+		})
+	}
+	return result, nil
+}
+
+func NotifyQueueMembers(db *gorm.DB, hub *Hub, message string, queue models.Queue) {
+	var members []models.QueueMember
+	if err := db.Where("queue_id = ? AND NOT (firstname = ? AND lastname = ?)", queue.ID, queue.Firstname, queue.Lastname).
+		Find(&members).Error; err != nil {
+		log.Printf("Error fetching members of queue %d: %v", queue.ID, err)
+		return
+	}
+	for _, member := range members {
+		userIdentifier := map[string]string{
+			"firstName": member.Firstname,
+			"lastName":  member.Lastname,
+		}
+		if err := SendPushNotification(db, hub, message, userIdentifier, nil); err != nil {
+			log.Printf("Error notifying member %d of queue %d: %v", member.ID, queue.ID, err)
+		}
+	}
+}
+
+func claimedMember(c *gin.Context, db *gorm.DB, queueID int) (models.QueueMember, error) {
+	var member models.QueueMember
+	claims, ok := c.Get("claims")
+	if !ok {
+		return member, gorm.ErrRecordNotFound
+	}
+	userClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return member, gorm.ErrRecordNotFound
+	}
+	query := db.Where("queue_id = ?", queueID)
+	if studentID, ok := userClaims["studentId"].(string); ok && studentID != "" {
+		query = query.Where("student_id = ?", studentID)
+	} else {
+		firstName, _ := userClaims["firstName"].(string)
+		lastName, _ := userClaims["lastName"].(string)
+		query = query.Where("firstname = ? AND lastname = ?", firstName, lastName)
+	}
+	err := query.First(&member).Error
+	return member, err
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"src/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testModels = []interface{}{
+	&models.Config{},
+	&models.Subscription{},
+	&models.Counter{},
+	&models.User{},
+	&models.Session{},
+	&models.Topic{},
+	&models.CounterTopic{},
+	&models.Queue{},
+	&models.QueueMember{},
+	&models.Attachment{},
+	&models.QueueSequence{},
+	&models.QueueTransfer{},
+	&models.QueueEvent{},
+	&models.PriorityGrant{},
+	&models.Appointment{},
+	&models.Feedback{},
+	&models.NotiSchedule{},
+	&models.AuditLog{},
+	&models.RateLimitBucket{},
+	&models.RateLimitBlock{},
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	db.Exec("SET TIME ZONE 'Asia/Bangkok'")
+	if err := db.AutoMigrate(testModels...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_queues_topic_date_no ON queues (topic_id, service_date, no)").Error; err != nil {
+		t.Fatalf("failed to create queue number index: %v", err)
+	}
+
+	var tables []string
+	for _, model := range testModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("failed to parse model: %v", err)
+		}
+		tables = append(tables, stmt.Schema.Table)
+	}
+	if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		t.Fatalf("failed to truncate test database: %v", err)
+	}
+	return db
+}
+
+func startTestHub() *Hub {
+	hub := NewHub()
+	go hub.Run()
+	return hub
+}
+
+func adminClaims() jwt.MapClaims {
+	return jwt.MapClaims{"role": "Admin", "email": "admin@example.com"}
+}
+
+func studentClaims(studentID string) jwt.MapClaims {
+	return jwt.MapClaims{"role": "Student", "studentId": studentID, "firstName": "Student", "lastName": studentID}
+}
+
+func performRequest(handler gin.HandlerFunc, method, path, body string, claims jwt.MapClaims, params gin.Params) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	if claims != nil {
+		c.Set("claims", claims)
+	}
+	handler(c)
+	c.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func seedTopic(t *testing.T, db *gorm.DB, code string) models.Topic {
+	t.Helper()
+	topic := newTopic(code, code, code)
+	if err := db.Create(&topic).Error; err != nil {
+		t.Fatalf("failed to seed topic: %v", err)
+	}
+	return topic
+}
+
+func seedCounter(t *testing.T, db *gorm.DB, name string, topics ...models.Topic) models.Counter {
+	t.Helper()
+	counter := models.Counter{Counter: name, Status: true}
+	if err := db.Create(&counter).Error; err != nil {
+		t.Fatalf("failed to seed counter: %v", err)
+	}
+	for _, topic := range topics {
+		if err := db.Create(&models.CounterTopic{CounterID: counter.ID, TopicID: topic.ID}).Error; err != nil {
+			t.Fatalf("failed to link counter topic: %v", err)
+		}
+	}
+	return counter
+}
+
+func seedQueue(t *testing.T, db *gorm.DB, queue models.Queue) models.Queue {
+	t.Helper()
+	if queue.Firstname == "" {
+		queue.Firstname = "Test"
+	}
+	if queue.Lastname == "" {
+		queue.Lastname = queue.No
+	}
+	if err := db.Create(&queue).Error; err != nil {
+		t.Fatalf("failed to seed queue: %v", err)
+	}
+	return queue
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"src/helpers"
+	"src/middleware"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func HoldQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		body := new(struct {
+			HoldMinutes *int       `json:"holdMinutes"`
+			HoldUntil   *time.Time `json:"holdUntil"`
+			Reason      *string    `json:"reason"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		holdUntil := body.HoldUntil
+		if body.HoldMinutes != nil {
+			if *body.HoldMinutes < 1 {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "holdMinutes must be at least 1")
+				return
+			}
+			until := time.Now().Add(time.Duration(*body.HoldMinutes) * time.Minute)
+			holdUntil = &until
+		}
+		if holdUntil != nil && !holdUntil.After(time.Now()) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "holdUntil must be in the future")
+			return
+		}
+
+		var queue models.Queue
+		if err := db.Preload("Topic").First(&queue, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if !canServeQueue(c, db, queue) {
+			return
+		}
+
+		before := queue
+		if err := TransitionQueue(db, &queue, helpers.HOLD, map[string]interface{}{
+			"hold_until":  holdUntil,
+			"hold_reason": body.Reason,
+		}, queueActor(c, db)); err != nil {
+			transitionErrorResponse(c, err, "Failed to hold queue")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "holdQueue",
+			"data":  queue,
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "hold", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, queue)
+	}
+}
+
+func ResumeQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		body := new(struct {
+			Counter int `json:"counter"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || body.Counter == 0 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "counter is required to resume a queue")
+			return
+		}
+		if !middleware.CanAccessCounter(c, body.Counter) {
+			return
+		}
+
+		var queue models.Queue
+		if err := db.Preload("Topic").First(&queue, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if queue.Status != helpers.HOLD {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only held queues can be resumed")
+			return
+		}
+		if queue.CounterID == nil || *queue.CounterID != body.Counter {
+			var count int64
+			if err := db.Model(&models.CounterTopic{}).
+				Where("counter_id = ? AND topic_id = ?", body.Counter, queue.TopicID).
+				Count(&count).Error; err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check counter topics")
+				return
+			}
+			if count == 0 {
+				helpers.FormatErrorResponse(c, http.StatusForbidden, "This counter does not serve the queue's topic")
+				return
+			}
+		}
+
+		before := queue
+		actor := queueActor(c, db)
+		var finished []models.Queue
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var counter models.Counter
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, body.Counter).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("counter_id = ? AND status IN ?", body.Counter, []helpers.STATUS{helpers.CALLING, helpers.SERVING}).
+				Find(&finished).Error; err != nil {
+				return err
+			}
+			for i := range finished {
+				if err := FinishQueue(tx, &finished[i], actor); err != nil {
+					return err
+				}
+			}
+			return TransitionQueue(tx, &queue, helpers.CALLING, map[string]interface{}{
+				"counter_id": body.Counter,
+				"hold_until": nil,
+			}, actor)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Counter not found")
+			return
+		}
+		if err != nil {
+			transitionErrorResponse(c, err, "Failed to resume queue")
+			return
+		}
+
+		called := 0
+		if len(finished) > 0 {
+			called = finished[0].ID
+		}
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "updateQueue",
+			"data": map[string]interface{}{
+				"current": queue,
+				"called":  called,
+				"resumed": true,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "resume", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, queue)
+	}
+}
+
+func ExpireHolds(db *gorm.DB, hub *Hub) error {
+	var queues []models.Queue
+	if err := db.Preload("Topic").
+		Where("status = ? AND hold_until IS NOT NULL AND hold_until < now()", helpers.HOLD).
+		Find(&queues).Error; err != nil {
+		return fmt.Errorf("failed to fetch expired holds: %v", err)
+	}
+
+	for i := range queues {
+		queue := &queues[i]
+		if err := TransitionQueue(db, queue, helpers.EXPIRED, nil, SystemActor); err != nil {
+			log.Printf("Error expiring hold for queue %d: %v", queue.ID, err)
+			continue
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "expireHold",
+			"data":  queue,
+		})
+		hub.broadcast <- message
+	}
+
+	if len(queues) > 0 {
+		log.Printf("Successfully expired %d held queues", len(queues))
+	}
+	return nil
+}
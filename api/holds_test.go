@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResumeQueueFinishesCounterCurrent(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+	counter := seedCounter(t, db, "1", topic)
+	current := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.CALLING, CounterID: &counter.ID})
+	held := seedQueue(t, db, models.Queue{No: "A002", TopicID: topic.ID, Status: helpers.HOLD, CounterID: &counter.ID})
+
+	res := performRequest(ResumeQueue(db, hub), http.MethodPut, "/queue/resume", fmt.Sprintf(`{"counter":%d}`, counter.ID),
+		adminClaims(), gin.Params{{Key: "id", Value: fmt.Sprint(held.ID)}})
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+
+	var live int64
+	db.Model(&models.Queue{}).Where("counter_id = ? AND status IN ?", counter.ID, []helpers.STATUS{helpers.CALLING, helpers.SERVING}).Count(&live)
+	if live != 1 {
+		t.Errorf("counter has %d live tickets, want 1", live)
+	}
+	var reloaded models.Queue
+	db.First(&reloaded, current.ID)
+	if reloaded.Status != helpers.DONE {
+		t.Errorf("previous ticket is %s, want DONE", reloaded.Status)
+	}
+	db.First(&reloaded, held.ID)
+	if reloaded.Status != helpers.CALLING {
+		t.Errorf("resumed ticket is %s, want CALLING", reloaded.Status)
+	}
+}
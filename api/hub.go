@@ -2,11 +2,18 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"src/helpers"
+	"src/middleware"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 const (
@@ -17,9 +24,78 @@ const (
 )
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	role      string
+	sessionID string
+}
+
+var personalDataKeys = map[string]bool{
+	"firstName":   true,
+	"lastName":    true,
+	"studentId":   true,
+	"fileName":    true,
+	"note":        true,
+	"email":       true,
+	"firstNameTH": true,
+	"lastNameTH":  true,
+	"firstNameEN": true,
+	"lastNameEN":  true,
+}
+
+func (c *Client) canSeePersonalData() bool {
+	switch c.role {
+	case helpers.ADMIN, helpers.COUNTER_STAFF:
+		return true
+	}
+	return false
+}
+
+func (c *Client) canRebroadcast() bool {
+	switch c.role {
+	case helpers.ADMIN, helpers.COUNTER_STAFF:
+		return true
+	}
+	return false
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if personalDataKeys[key] {
+				delete(v, key)
+				continue
+			}
+			if key == "priority" {
+				if priority, ok := nested.(string); ok && priority != string(helpers.PRIORITY_NORMAL) {
+					v[key] = "PRIORITY"
+				}
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+		return v
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+		return v
+	}
+	return value
+}
+
+func redactMessage(message []byte) []byte {
+	var payload interface{}
+	if err := json.Unmarshal(message, &payload); err != nil {
+		return message
+	}
+	redacted, err := json.Marshal(redactValue(payload))
+	if err != nil {
+		return message
+	}
+	return redacted
 }
 
 type Hub struct {
@@ -27,6 +103,7 @@ type Hub struct {
 	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	disconnect chan []string
 }
 
 func NewHub() *Hub {
@@ -35,6 +112,7 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		disconnect: make(chan []string),
 	}
 }
 
@@ -42,6 +120,10 @@ func (h *Hub) Broadcast(message []byte) {
 	h.broadcast <- message
 }
 
+func (h *Hub) DisconnectSessions(sessionIDs []string) {
+	h.disconnect <- sessionIDs
+}
+
 func (h *Hub) Run() {
 	for {
 		select {
@@ -52,9 +134,31 @@ func (h *Hub) Run() {
 				delete(h.clients, client)
 				client.conn.Close()
 			}
+		case sessionIDs := <-h.disconnect:
+			revoked := make(map[string]bool, len(sessionIDs))
+			for _, sessionID := range sessionIDs {
+				revoked[sessionID] = true
+			}
+			for client := range h.clients {
+				if client.sessionID == "" || !revoked[client.sessionID] {
+					continue
+				}
+				client.conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Session has been revoked"), time.Now().Add(writeWait))
+				client.conn.Close()
+				delete(h.clients, client)
+			}
 		case message := <-h.broadcast:
+			var redacted []byte
 			for client := range h.clients {
-				if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				payload := message
+				if !client.canSeePersonalData() {
+					if redacted == nil {
+						redacted = redactMessage(message)
+					}
+					payload = redacted
+				}
+				if err := client.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
 					log.Println("WebSocket write error:", err)
 					client.conn.Close()
 					delete(h.clients, client)
@@ -64,20 +168,66 @@ func (h *Hub) Run() {
 	}
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
 		return true
-	},
+	}
+	allowedOrigins := os.Getenv("WS_ALLOWED_ORIGINS")
+	if allowedOrigins == "" {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range strings.Split(allowedOrigins, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+}
+
+func websocketToken(r *http.Request) (string, string) {
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) == 2 && strings.EqualFold(protocols[0], "bearer") {
+		return protocols[1], protocols[0]
+	}
+	return "", ""
 }
 
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func ServeWs(hub *Hub, db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	role, sessionID := "", ""
+	token, subprotocol := websocketToken(r)
+	if token != "" {
+		claims, err := middleware.Authenticate(db, token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		role, _ = claims["role"].(string)
+		sessionID, _ = claims["sid"].(string)
+	}
+
+	var responseHeader http.Header
+	if subprotocol != "" {
+		responseHeader = http.Header{"Sec-Websocket-Protocol": {subprotocol}}
+	}
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
 		log.Println("WebSocket upgrade failed:", err)
 		return
 	}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), role: role, sessionID: sessionID}
 	hub.register <- client
 
 	go client.writePump()
@@ -104,6 +254,9 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		if !c.canRebroadcast() {
+			continue
+		}
 		message = bytes.TrimSpace(message)
 		c.hub.broadcast <- message
 	}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"src/helpers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebsocketTokenIgnoresQueryParameter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api?token=secret", nil)
+	if token, _ := websocketToken(req); token != "" {
+		t.Errorf("token read from query parameter: %s", token)
+	}
+	req.Header.Set("Sec-WebSocket-Protocol", "bearer, secret")
+	if token, protocol := websocketToken(req); token != "secret" || protocol != "bearer" {
+		t.Errorf("websocketToken = %s, %s, want secret, bearer", token, protocol)
+	}
+}
+
+func TestOnlyStaffSocketsSeeAndSendPersonalData(t *testing.T) {
+	cases := map[string]bool{
+		helpers.ADMIN:         true,
+		helpers.COUNTER_STAFF: true,
+		helpers.VIEWER:        false,
+		helpers.STUDENT:       false,
+		helpers.GUEST:         false,
+		"":                    false,
+	}
+	for role, allowed := range cases {
+		client := &Client{role: role}
+		if client.canSeePersonalData() != allowed || client.canRebroadcast() != allowed {
+			t.Errorf("role %q: canSeePersonalData=%v canRebroadcast=%v, want %v", role, client.canSeePersonalData(), client.canRebroadcast(), allowed)
+		}
+	}
+}
+
+func TestDisconnectSessionsClosesOnlyRevokedSockets(t *testing.T) {
+	hub := startTestHub()
+	registered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.register <- &Client{hub: hub, conn: conn, send: make(chan []byte, 256), role: "Admin", sessionID: r.URL.Query().Get("sid")}
+		registered <- struct{}{}
+	}))
+	defer server.Close()
+
+	dial := func(sessionID string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?sid="+sessionID, nil)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		<-registered
+		return conn
+	}
+	revoked := dial("revoked")
+	defer revoked.Close()
+	active := dial("active")
+	defer active.Close()
+
+	hub.Broadcast([]byte(`{"event":"ping"}`))
+	for _, conn := range []*websocket.Conn{revoked, active} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("failed to receive broadcast: %v", err)
+		}
+	}
+
+	hub.DisconnectSessions([]string{"revoked"})
+	hub.Broadcast([]byte(`{"event":"ping"}`))
+
+	revoked.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := revoked.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("revoked socket got %v, want policy violation close", err)
+	}
+	active.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := active.ReadMessage(); err != nil {
+		t.Errorf("active socket was disconnected: %v", err)
+	}
+}
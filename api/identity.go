@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"src/helpers"
+	"strings"
+)
+
+type Identity struct {
+	Email       string `json:"email"`
+	StudentID   string `json:"studentId"`
+	FirstNameTH string `json:"firstNameTH"`
+	LastNameTH  string `json:"lastNameTH"`
+	FirstNameEN string `json:"firstNameEN"`
+	LastNameEN  string `json:"lastNameEN"`
+	Faculty     string `json:"faculty"`
+	IsStudent   bool   `json:"isStudent"`
+}
+
+type IdentityProvider interface {
+	Name() string
+	ExchangeCode(code, redirectURI string) (string, error)
+	FetchProfile(accessToken string) (*Identity, error)
+	MapRole(identity *Identity) string
+}
+
+func NewIdentityProvider() IdentityProvider {
+	switch strings.ToLower(os.Getenv("IDENTITY_PROVIDER")) {
+	case "oidc":
+		return &OIDCProvider{
+			TokenURL:       os.Getenv("OIDC_TOKEN_URL"),
+			UserInfoURL:    os.Getenv("OIDC_USERINFO_URL"),
+			ClientID:       os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+			Scope:          os.Getenv("OIDC_SCOPE"),
+			StudentIDClaim: os.Getenv("OIDC_STUDENT_ID_CLAIM"),
+		}
+	case "mock":
+		log.Println("Using the mock identity provider, do not enable this in production")
+		return &MockProvider{}
+	default:
+		return &CMUProvider{}
+	}
+}
+
+type CMUProvider struct{}
+
+func (p *CMUProvider) Name() string {
+	return "EntraID"
+}
+
+func (p *CMUProvider) ExchangeCode(code, redirectURI string) (string, error) {
+	return getEntraIDAccessToken(code, redirectURI)
+}
+
+func (p *CMUProvider) FetchProfile(accessToken string) (*Identity, error) {
+	info, err := getCMUBasicInfo(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Email:       info.CmuitAccount,
+		StudentID:   info.StudentID,
+		FirstNameTH: info.FirstnameTH,
+		LastNameTH:  info.LastnameTH,
+		FirstNameEN: info.FirstnameEN,
+		LastNameEN:  info.LastnameEN,
+		Faculty:     info.OrganizationNameTH,
+		IsStudent:   info.ItAccountTypeID == STUDENT.String(),
+	}, nil
+}
+
+func (p *CMUProvider) MapRole(identity *Identity) string {
+	if identity.IsStudent {
+		return helpers.STUDENT
+	}
+	return ""
+}
+
+type OIDCProvider struct {
+	TokenURL       string
+	UserInfoURL    string
+	ClientID       string
+	ClientSecret   string
+	Scope          string
+	StudentIDClaim string
+}
+
+func (p *OIDCProvider) Name() string {
+	return "OIDC"
+}
+
+func (p *OIDCProvider) ExchangeCode(code, redirectURI string) (string, error) {
+	data := url.Values{}
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+	data.Set("client_id", p.ClientID)
+	data.Set("client_secret", p.ClientSecret)
+	if p.Scope != "" {
+		data.Set("scope", p.Scope)
+	}
+	return exchangeAuthorizationCode(p.TokenURL, data)
+}
+
+func (p *OIDCProvider) FetchProfile(accessToken string) (*Identity, error) {
+	req, err := http.NewRequest("GET", p.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OIDC userinfo, status: %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, err
+	}
+
+	stringClaim := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+	studentIDClaim := p.StudentIDClaim
+	if studentIDClaim == "" {
+		studentIDClaim = "student_id"
+	}
+
+	identity := &Identity{
+		Email:       stringClaim("email"),
+		StudentID:   stringClaim(studentIDClaim),
+		FirstNameEN: stringClaim("given_name"),
+		LastNameEN:  stringClaim("family_name"),
+	}
+	if identity.Email == "" {
+		return nil, errors.New("OIDC userinfo is missing the email claim")
+	}
+	identity.IsStudent = identity.StudentID != ""
+	return identity, nil
+}
+
+func (p *OIDCProvider) MapRole(identity *Identity) string {
+	if identity.IsStudent {
+		return helpers.STUDENT
+	}
+	return ""
+}
+
+const (
+	MockStaffEmail = "staff.mock@cmu.ac.th"
+	MockStaffRole  = helpers.ADMIN
+)
+
+var mockIdentities = map[string]Identity{
+	"student": {
+		Email:       "student.mock@cmu.ac.th",
+		StudentID:   "650610001",
+		FirstNameTH: "นักศึกษา",
+		LastNameTH:  "ทดสอบ",
+		FirstNameEN: "Student",
+		LastNameEN:  "Mock",
+		Faculty:     "คณะวิศวกรรมศาสตร์",
+		IsStudent:   true,
+	},
+	"staff": {
+		Email:       MockStaffEmail,
+		FirstNameTH: "เจ้าหน้าที่",
+		LastNameTH:  "ทดสอบ",
+		FirstNameEN: "Staff",
+		LastNameEN:  "Mock",
+		Faculty:     "คณะวิศวกรรมศาสตร์",
+	},
+	"non-cmu": {
+		Email:       "visitor.mock@example.com",
+		FirstNameEN: "Visitor",
+		LastNameEN:  "Mock",
+	},
+}
+
+type MockProvider struct{}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) ExchangeCode(code, redirectURI string) (string, error) {
+	if _, ok := mockIdentities[code]; !ok {
+		return "", fmt.Errorf("unknown mock identity %q", code)
+	}
+	return "mock:" + code, nil
+}
+
+func (p *MockProvider) FetchProfile(accessToken string) (*Identity, error) {
+	identity, ok := mockIdentities[strings.TrimPrefix(accessToken, "mock:")]
+	if !ok {
+		return nil, errors.New("invalid mock access token")
+	}
+	return &identity, nil
+}
+
+func (p *MockProvider) MapRole(identity *Identity) string {
+	switch {
+	case identity.IsStudent:
+		return helpers.STUDENT
+	case identity.Email == MockStaffEmail:
+		return MockStaffRole
+	}
+	return helpers.GUEST
+}
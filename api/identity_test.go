@@ -0,0 +1,21 @@
+package api
+
+import (
+	"src/helpers"
+	"testing"
+)
+
+func TestMockProviderMapsEveryIdentity(t *testing.T) {
+	provider := &MockProvider{}
+	want := map[string]string{
+		"student": helpers.STUDENT,
+		"staff":   MockStaffRole,
+		"non-cmu": helpers.GUEST,
+	}
+	for code, role := range want {
+		identity := mockIdentities[code]
+		if got := provider.MapRole(&identity); got != role {
+			t.Errorf("MapRole(%s) = %q, want %q", code, got, role)
+		}
+	}
+}
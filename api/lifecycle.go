@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/middleware"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var ErrIllegalTransition = errors.New("illegal queue state transition")
+
+var queueTransitions = map[helpers.STATUS][]helpers.STATUS{
+	helpers.WAITING: {helpers.CALLING, helpers.CANCELLED},
+	helpers.CALLING: {helpers.SERVING, helpers.SKIPPED, helpers.NO_SHOW, helpers.CANCELLED, helpers.WAITING, helpers.HOLD},
+	helpers.SERVING: {helpers.DONE, helpers.WAITING, helpers.HOLD},
+	helpers.SKIPPED: {helpers.CALLING, helpers.WAITING, helpers.NO_SHOW, helpers.CANCELLED},
+	helpers.HOLD:    {helpers.CALLING, helpers.EXPIRED, helpers.CANCELLED},
+}
+
+func CanTransition(from, to helpers.STATUS) bool {
+	for _, next := range queueTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func TransitionQueue(tx *gorm.DB, queue *models.Queue, to helpers.STATUS, updates map[string]interface{}, actor QueueActor) error {
+	if !CanTransition(queue.Status, to) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, queue.Status, to)
+	}
+
+	now := time.Now()
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+	switch to {
+	case helpers.CALLING:
+		updates["called_at"] = now
+		updates["last_called_at"] = now
+	case helpers.SERVING:
+		updates["served_at"] = now
+	case helpers.HOLD:
+		updates["held_at"] = now
+	case helpers.DONE, helpers.CANCELLED, helpers.NO_SHOW, helpers.EXPIRED:
+		updates["finished_at"] = now
+	}
+
+	result := tx.Model(&models.Queue{}).Where("id = ? AND status = ?", queue.ID, queue.Status).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: queue %d is no longer %s", ErrIllegalTransition, queue.ID, queue.Status)
+	}
+	from := queue.Status
+	if err := tx.Preload("Topic").First(queue, queue.ID).Error; err != nil {
+		return err
+	}
+	return RecordQueueEvent(tx, *queue, helpers.QUEUE_EVENT_TRANSITION, &from, actor, nil)
+}
+
+func FinishQueue(tx *gorm.DB, queue *models.Queue, actor QueueActor) error {
+	if queue.Status == helpers.CALLING {
+		if err := TransitionQueue(tx, queue, helpers.SERVING, nil, actor); err != nil {
+			return err
+		}
+	}
+	return TransitionQueue(tx, queue, helpers.DONE, nil, actor)
+}
+
+func finishableAt(queue models.Queue, counterID int) bool {
+	if queue.Status != helpers.CALLING && queue.Status != helpers.SERVING {
+		return false
+	}
+	return queue.CounterID != nil && *queue.CounterID == counterID
+}
+
+func transitionErrorResponse(c *gin.Context, err error, message string) {
+	if errors.Is(err, ErrIllegalTransition) {
+		helpers.FormatErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	helpers.FormatErrorResponse(c, http.StatusInternalServerError, message)
+}
+
+func UpdateQueueState(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		body := new(struct {
+			State   string `json:"state"`
+			Counter *int   `json:"counter"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || body.State == "" {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		to := helpers.ParseStatus(body.State)
+
+		var queue models.Queue
+		if err := db.Preload("Topic").First(&queue, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if !canServeQueue(c, db, queue) {
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if to == helpers.CALLING {
+			if body.Counter == nil {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "counter is required to call a queue")
+				return
+			}
+			if !middleware.CanAccessCounter(c, *body.Counter) {
+				return
+			}
+			updates["counter_id"] = *body.Counter
+		}
+		if to == helpers.WAITING {
+			updates["counter_id"] = nil
+		}
+
+		before := queue
+		if err := TransitionQueue(db, &queue, to, updates, queueActor(c, db)); err != nil {
+			transitionErrorResponse(c, err, "Failed to update queue state")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "updateQueueState",
+			"data": map[string]interface{}{
+				"queue": queue,
+				"from":  before.Status,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "state", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, queue)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"src/helpers"
+	"src/models"
+	"testing"
+)
+
+func TestCanTransitionRejectsIllegalMoves(t *testing.T) {
+	cases := []struct {
+		from helpers.STATUS
+		to   helpers.STATUS
+	}{
+		{helpers.WAITING, helpers.DONE},
+		{helpers.WAITING, helpers.SERVING},
+		{helpers.WAITING, helpers.HOLD},
+		{helpers.CALLING, helpers.DONE},
+		{helpers.CALLING, helpers.EXPIRED},
+		{helpers.SERVING, helpers.CALLING},
+		{helpers.SERVING, helpers.NO_SHOW},
+		{helpers.SKIPPED, helpers.DONE},
+		{helpers.HOLD, helpers.SERVING},
+		{helpers.HOLD, helpers.DONE},
+		{helpers.DONE, helpers.WAITING},
+		{helpers.CANCELLED, helpers.CALLING},
+		{helpers.NO_SHOW, helpers.CALLING},
+		{helpers.EXPIRED, helpers.HOLD},
+		{helpers.CALLING, helpers.CALLING},
+	}
+	for _, tc := range cases {
+		if CanTransition(tc.from, tc.to) {
+			t.Errorf("CanTransition(%s, %s) = true, want false", tc.from, tc.to)
+		}
+	}
+}
+
+func TestTransitionQueueRejectsIllegalMove(t *testing.T) {
+	db := openTestDB(t)
+	topic := seedTopic(t, db, "A")
+	queue := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.WAITING})
+
+	err := TransitionQueue(db, &queue, helpers.DONE, nil, SystemActor)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("expected ErrIllegalTransition, got %v", err)
+	}
+	var reloaded models.Queue
+	db.First(&reloaded, queue.ID)
+	if reloaded.Status != helpers.WAITING || reloaded.FinishedAt != nil {
+		t.Errorf("queue changed to %s, want untouched WAITING", reloaded.Status)
+	}
+}
+
+func TestTransitionQueueRejectsStaleStatus(t *testing.T) {
+	db := openTestDB(t)
+	topic := seedTopic(t, db, "A")
+	queue := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.WAITING})
+	stale := queue
+
+	if err := TransitionQueue(db, &queue, helpers.CANCELLED, nil, SystemActor); err != nil {
+		t.Fatalf("first transition failed: %v", err)
+	}
+	err := TransitionQueue(db, &stale, helpers.CALLING, nil, SystemActor)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("expected ErrIllegalTransition, got %v", err)
+	}
+	var reloaded models.Queue
+	db.First(&reloaded, queue.ID)
+	if reloaded.Status != helpers.CANCELLED || reloaded.CalledAt != nil {
+		t.Errorf("stale transition overwrote the row: status %s", reloaded.Status)
+	}
+}
+
+func TestTerminalStatesHaveNoTransitions(t *testing.T) {
+	for _, status := range []helpers.STATUS{helpers.DONE, helpers.CANCELLED, helpers.NO_SHOW, helpers.EXPIRED} {
+		if len(queueTransitions[status]) != 0 {
+			t.Errorf("%s should be terminal, has transitions %v", status, queueTransitions[status])
+		}
+	}
+}
+
+func TestFinishableAt(t *testing.T) {
+	counter := 3
+	other := 4
+	cases := []struct {
+		status    helpers.STATUS
+		counterID *int
+		want      bool
+	}{
+		{helpers.CALLING, &counter, true},
+		{helpers.SERVING, &counter, true},
+		{helpers.CALLING, &other, false},
+		{helpers.CANCELLED, &counter, false},
+		{helpers.NO_SHOW, &counter, false},
+		{helpers.WAITING, nil, false},
+	}
+	for _, tc := range cases {
+		queue := models.Queue{Status: tc.status, CounterID: tc.counterID}
+		if got := finishableAt(queue, counter); got != tc.want {
+			t.Errorf("finishableAt(%s) = %v, want %v", tc.status, got, tc.want)
+		}
+	}
+}
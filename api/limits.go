@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ticketLimitError struct {
+	Code    helpers.ERROR_CODE
+	Message string
+	Queue   models.Queue
+}
+
+func (e *ticketLimitError) Error() string {
+	return e.Message
+}
+
+func ticketHolderKey(queue models.Queue) string {
+	if queue.StudentID != nil {
+		return "student:" + *queue.StudentID
+	}
+	return "guest:" + queue.Firstname + " " + queue.Lastname
+}
+
+func ticketHolderQueues(tx *gorm.DB, queue models.Queue) *gorm.DB {
+	query := tx.Model(&models.Queue{}).Preload("Topic")
+	if queue.StudentID != nil {
+		return query.Where("student_id = ?", *queue.StudentID)
+	}
+	return query.Where("student_id IS NULL AND firstname = ? AND lastname = ?", queue.Firstname, queue.Lastname)
+}
+
+func checkTicketLimits(tx *gorm.DB, queue models.Queue) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", ticketHolderKey(queue)).Error; err != nil {
+		return err
+	}
+	config, err := loadConfig(tx)
+	if err != nil {
+		return err
+	}
+
+	var existing models.Queue
+	if config.OneTicketPerTopic {
+		err := ticketHolderQueues(tx, queue).
+			Where("topic_id = ? AND service_date = ? AND status IN ?", queue.TopicID, queue.ServiceDate, helpers.ACTIVE_STATUSES).
+			First(&existing).Error
+		if err == nil {
+			return &ticketLimitError{Code: helpers.DUPLICATE_TOPIC_TICKET, Message: "You already have an active ticket for this topic", Queue: existing}
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if config.MaxActiveTickets > 0 {
+		var active []models.Queue
+		if err := ticketHolderQueues(tx, queue).
+			Where("service_date = ? AND status IN ?", queue.ServiceDate, helpers.ACTIVE_STATUSES).
+			Order("created_at ASC").
+			Find(&active).Error; err != nil {
+			return err
+		}
+		if len(active) >= config.MaxActiveTickets {
+			return &ticketLimitError{Code: helpers.TICKET_LIMIT_REACHED, Message: fmt.Sprintf("You can hold at most %d active tickets", config.MaxActiveTickets), Queue: active[0]}
+		}
+	}
+
+	if config.NoShowCooldownMinutes > 0 {
+		since := time.Now().Add(-time.Duration(config.NoShowCooldownMinutes) * time.Minute)
+		err := ticketHolderQueues(tx, queue).
+			Where("status = ? AND finished_at > ?", helpers.NO_SHOW, since).
+			Order("finished_at DESC").
+			First(&existing).Error
+		if err == nil {
+			retryAt := existing.FinishedAt.Add(time.Duration(config.NoShowCooldownMinutes) * time.Minute)
+			return &ticketLimitError{Code: helpers.NO_SHOW_COOLDOWN, Message: fmt.Sprintf("You missed a recent call, please try again after %s", retryAt.Format("15:04")), Queue: existing}
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func ticketLimitErrorResponse(c *gin.Context, err error) bool {
+	var limitErr *ticketLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	helpers.FormatErrorResponse(c, http.StatusConflict, map[string]interface{}{
+		"code":    limitErr.Code,
+		"message": limitErr.Message,
+		"queue":   limitErr.Queue,
+	})
+	return true
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"src/models"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTicketLimitsHoldUnderConcurrentReservations(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	if err := db.Create(&models.Config{LoginNotCmu: true, Audio: "th", MaxActiveTickets: 2, OneTicketPerTopic: true}).Error; err != nil {
+		t.Fatalf("failed to seed config: %v", err)
+	}
+	topics := []models.Topic{seedTopic(t, db, "A"), seedTopic(t, db, "B"), seedTopic(t, db, "C")}
+
+	const attempts = 12
+	var wg sync.WaitGroup
+	codes := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(topic models.Topic) {
+			defer wg.Done()
+			handler := func(c *gin.Context) {
+				c.Set("parsedBody", ReserveDTO{Topic: topic.ID})
+				CreateQueue(db, hub)(c)
+			}
+			codes <- performRequest(handler, http.MethodPost, "/queue", "", studentClaims("650000001"), nil).Code
+		}(topics[i%len(topics)])
+	}
+	wg.Wait()
+	close(codes)
+
+	created, rejected := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		case http.StatusConflict:
+			rejected++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if created != 2 || rejected != attempts-2 {
+		t.Errorf("created %d and rejected %d tickets, want 2 and %d", created, rejected, attempts-2)
+	}
+
+	var perTopic []int64
+	db.Model(&models.Queue{}).Where("student_id = ?", "650000001").Group("topic_id").Pluck("COUNT(*)", &perTopic)
+	for _, count := range perTopic {
+		if count > 1 {
+			t.Errorf("student holds %d tickets for one topic", count)
+		}
+	}
+}
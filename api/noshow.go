@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func validNoShowPolicy(topic models.Topic) error {
+	switch topic.NoShowAction {
+	case helpers.NO_SHOW_ACTION_NONE, helpers.NO_SHOW_ACTION_NO_SHOW, helpers.NO_SHOW_ACTION_REQUEUE:
+	default:
+		return fmt.Errorf("Invalid noShowAction '%v'", topic.NoShowAction)
+	}
+	if topic.NoShowMaxRecalls < 1 {
+		return fmt.Errorf("noShowMaxRecalls must be at least 1")
+	}
+	if topic.NoShowGraceMinutes < 1 {
+		return fmt.Errorf("noShowGraceMinutes must be at least 1")
+	}
+	if topic.RequeueOffset < 0 {
+		return fmt.Errorf("requeueOffset must not be negative")
+	}
+	return nil
+}
+
+func findRecalledQueue(db *gorm.DB, queueID *int, no, firstName, lastName string) (models.Queue, error) {
+	var queue models.Queue
+	query := db.Preload("Topic").Where("status = ?", helpers.CALLING)
+	if queueID != nil {
+		query = query.Where("id = ?", *queueID)
+	} else {
+		startOfDay, _ := helpers.GetStartAndEndOfDay()
+		query = query.Where("no = ? AND firstname = ? AND lastname = ? AND service_date = ?", no, firstName, lastName, startOfDay)
+	}
+	err := query.Order("last_called_at DESC").First(&queue).Error
+	return queue, err
+}
+
+func recordRecall(db *gorm.DB, queue *models.Queue) error {
+	now := time.Now()
+	err := db.Model(queue).Updates(map[string]interface{}{
+		"recall_count":   gorm.Expr("recall_count + 1"),
+		"last_called_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	queue.RecallCount++
+	queue.LastCalledAt = &now
+	return nil
+}
+
+func requeueOrderAt(tx *gorm.DB, queue models.Queue, offset int) (time.Time, error) {
+	config, err := loadConfig(tx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	limit := offset
+	if limit == 0 {
+		limit = 1
+	}
+	var ahead []models.Queue
+	if err := tx.Where("topic_id = ? AND status = ? AND id != ?", queue.TopicID, helpers.WAITING, queue.ID).
+		Order(waitingOrder(config)).
+		Limit(limit).
+		Find(&ahead).Error; err != nil {
+		return time.Time{}, err
+	}
+	if offset == 0 && len(ahead) > 0 {
+		return ahead[0].OrderAt.Add(-time.Microsecond), nil
+	}
+	if offset == 0 || len(ahead) < offset {
+		return time.Now(), nil
+	}
+	return ahead[offset-1].OrderAt.Add(time.Microsecond), nil
+}
+
+func noShowMessage(outcome helpers.STATUS) map[string]interface{} {
+	if outcome == helpers.WAITING {
+		return map[string]interface{}{
+			"title": map[string]string{
+				"en": "You missed your call",
+				"th": "คุณพลาดการเรียกคิว",
+			},
+			"body": map[string]string{
+				"en": "Your ticket has been moved back into the line.",
+				"th": "คิวของคุณถูกย้ายกลับไปรอในลำดับถัดไป",
+			},
+			"url": "/student-dashboard/queue",
+		}
+	}
+	return map[string]interface{}{
+		"title": map[string]string{
+			"en": "Your ticket has expired",
+			"th": "คิวของคุณหมดอายุแล้ว",
+		},
+		"body": map[string]string{
+			"en": "You did not show up after being called. Please take a new ticket.",
+			"th": "คุณไม่ได้มาตามที่ถูกเรียก กรุณากดรับคิวใหม่",
+		},
+		"url": "/student-dashboard/queue",
+	}
+}
+
+func resolveNoShow(db *gorm.DB, queue *models.Queue) (helpers.STATUS, error) {
+	outcome := helpers.NO_SHOW
+	if queue.Topic.NoShowAction == helpers.NO_SHOW_ACTION_REQUEUE && queue.RequeueCount == 0 {
+		outcome = helpers.WAITING
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if outcome == helpers.NO_SHOW {
+			return TransitionQueue(tx, queue, helpers.NO_SHOW, nil, SystemActor)
+		}
+		orderAt, err := requeueOrderAt(tx, *queue, queue.Topic.RequeueOffset)
+		if err != nil {
+			return err
+		}
+		return TransitionQueue(tx, queue, helpers.WAITING, map[string]interface{}{
+			"counter_id":    nil,
+			"order_at":      orderAt,
+			"recall_count":  0,
+			"requeue_count": gorm.Expr("requeue_count + 1"),
+		}, SystemActor)
+	})
+	return outcome, err
+}
+
+func ResolveNoShows(db *gorm.DB, hub *Hub) error {
+	var queues []models.Queue
+	err := db.Preload("Topic").
+		Joins("JOIN topics ON topics.id = queues.topic_id").
+		Where("queues.status = ? AND topics.no_show_action != ?", helpers.CALLING, helpers.NO_SHOW_ACTION_NONE).
+		Where("queues.recall_count >= topics.no_show_max_recalls").
+		Where("queues.last_called_at + topics.no_show_grace_minutes * INTERVAL '1 minute' < now()").
+		Find(&queues).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch unanswered queues: %v", err)
+	}
+
+	for i := range queues {
+		queue := &queues[i]
+		outcome, err := resolveNoShow(db, queue)
+		if err != nil {
+			log.Printf("Error resolving no-show for queue %d: %v", queue.ID, err)
+			continue
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "noShowQueue",
+			"data": map[string]interface{}{
+				"queue":   queue,
+				"outcome": outcome,
+			},
+		})
+		hub.broadcast <- message
+
+		go func(queue models.Queue, outcome helpers.STATUS) {
+			messageJSON, err := json.Marshal(noShowMessage(outcome))
+			if err != nil {
+				log.Printf("Error creating no-show notification for queue %d: %v", queue.ID, err)
+				return
+			}
+			userIdentifier := map[string]string{
+				"firstName": queue.Firstname,
+				"lastName":  queue.Lastname,
+			}
+			if err := SendPushNotification(db, hub, string(messageJSON), userIdentifier, nil); err != nil {
+				log.Printf("Error sending no-show notification for queue %d: %v", queue.ID, err)
+			}
+			NotifyQueueMembers(db, hub, string(messageJSON), queue)
+		}(*queue, outcome)
+	}
+
+	if len(queues) > 0 {
+		log.Printf("Successfully resolved %d unanswered queues", len(queues))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"src/helpers"
+	"src/models"
+	"testing"
+	"time"
+)
+
+func TestResolveNoShowsAfterGraceFollowingLastRecall(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+	db.Model(&topic).Updates(map[string]interface{}{
+		"no_show_action":        helpers.NO_SHOW_ACTION_NO_SHOW,
+		"no_show_max_recalls":   3,
+		"no_show_grace_minutes": 5,
+	})
+	counter := seedCounter(t, db, "1", topic)
+
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-10 * time.Minute)
+	resolved := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.CALLING, CounterID: &counter.ID, LastCalledAt: &stale, RecallCount: 3})
+	inGrace := seedQueue(t, db, models.Queue{No: "A002", TopicID: topic.ID, Status: helpers.CALLING, CounterID: &counter.ID, LastCalledAt: &recent, RecallCount: 3})
+	recallsLeft := seedQueue(t, db, models.Queue{No: "A003", TopicID: topic.ID, Status: helpers.CALLING, CounterID: &counter.ID, LastCalledAt: &stale, RecallCount: 1})
+
+	if err := ResolveNoShows(db, hub); err != nil {
+		t.Fatalf("ResolveNoShows failed: %v", err)
+	}
+
+	want := map[int]helpers.STATUS{
+		resolved.ID:    helpers.NO_SHOW,
+		inGrace.ID:     helpers.CALLING,
+		recallsLeft.ID: helpers.CALLING,
+	}
+	for id, status := range want {
+		var reloaded models.Queue
+		db.First(&reloaded, id)
+		if reloaded.Status != status {
+			t.Errorf("queue %s is %s, want %s", reloaded.No, reloaded.Status, status)
+		}
+	}
+}
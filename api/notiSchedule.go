@@ -33,6 +33,7 @@ func CreateNotiSchedule(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, "create", "notiSchedule", body.ID, nil, body)
 		helpers.FormatSuccessResponse(c, body)
 	}
 }
@@ -51,12 +52,14 @@ func UpdateNotiSchedule(db *gorm.DB) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusNotFound, "Notification schedule not found")
 			return
 		}
+		before := notiSchedule
 
 		if err := db.Model(&notiSchedule).Updates(body).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update notification schedule")
 			return
 		}
 
+		recordAudit(db, c, "update", "notiSchedule", notiSchedule.ID, before, notiSchedule)
 		helpers.FormatSuccessResponse(c, notiSchedule)
 	}
 }
@@ -64,11 +67,17 @@ func UpdateNotiSchedule(db *gorm.DB) gin.HandlerFunc {
 func DeleteNotiSchedule(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
+		var notiSchedule models.NotiSchedule
+		if err := db.First(&notiSchedule, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Notification schedule not found")
+			return
+		}
 		if err := db.Delete(&models.NotiSchedule{}, id).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusNotFound, "Notification schedule not found")
 			return
 		}
 
+		recordAudit(db, c, "delete", "notiSchedule", notiSchedule.ID, notiSchedule, nil)
 		helpers.FormatSuccessResponse(c, map[string]string{"message": "Notification schedule deleted successfully"})
 	}
 }
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func queueNoPrefix(topic models.Topic) string {
+	if topic.NoPrefix != nil {
+		return *topic.NoPrefix
+	}
+	return topic.Code
+}
+
+func formatQueueNo(topic models.Topic, sequence int) string {
+	step := topic.NoStep
+	if step < 1 {
+		step = 1
+	}
+	return fmt.Sprintf("%s%0*d", queueNoPrefix(topic), topic.NoWidth, topic.NoStart+(sequence-1)*step)
+}
+
+func numberingPeriodStart(topic models.Topic, serviceDate time.Time, config models.Config) time.Time {
+	switch topic.NoReset {
+	case helpers.RESET_WEEKLY:
+		offset := (int(serviceDate.Weekday()) + 6) % 7
+		return serviceDate.AddDate(0, 0, -offset)
+	case helpers.RESET_TERM:
+		periodStart := time.Date(serviceDate.Year(), time.January, 1, 0, 0, 0, 0, serviceDate.Location())
+		for _, termStart := range config.TermStarts {
+			start, err := time.ParseInLocation("2006-01-02", termStart, serviceDate.Location())
+			if err == nil && !start.After(serviceDate) && start.After(periodStart) {
+				periodStart = start
+			}
+		}
+		return periodStart
+	case helpers.RESET_NEVER:
+		return time.Date(1970, time.January, 1, 0, 0, 0, 0, serviceDate.Location())
+	}
+	return serviceDate
+}
+
+func validNumberingPolicy(topic models.Topic) error {
+	switch topic.NoReset {
+	case helpers.RESET_DAILY, helpers.RESET_WEEKLY, helpers.RESET_TERM, helpers.RESET_NEVER:
+	default:
+		return fmt.Errorf("Invalid noReset '%v'", topic.NoReset)
+	}
+	if topic.NoWidth < 1 || topic.NoWidth > 10 {
+		return fmt.Errorf("noWidth must be between 1 and 10")
+	}
+	if topic.NoStart < 0 {
+		return fmt.Errorf("noStart must not be negative")
+	}
+	if topic.NoStep < 1 {
+		return fmt.Errorf("noStep must be at least 1")
+	}
+	return nil
+}
+
+func allocateQueueNo(tx *gorm.DB, topic models.Topic, serviceDate time.Time) (string, error) {
+	config, err := loadConfig(tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %v", err)
+	}
+
+	var lastNo int
+	err = tx.Raw(`
+		INSERT INTO queue_sequences (topic_id, period_start, last_no) VALUES (?, ?, 1)
+		ON CONFLICT (topic_id, period_start) DO UPDATE SET last_no = queue_sequences.last_no + 1
+		RETURNING last_no
+	`, topic.ID, numberingPeriodStart(topic, serviceDate, config)).Scan(&lastNo).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate queue number: %v", err)
+	}
+	return formatQueueNo(topic, lastNo), nil
+}
+
+func PreviewQueueNo(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var topic models.Topic
+		if err := db.First(&topic, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
+			return
+		}
+		config, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+
+		startOfDay, _ := helpers.GetStartAndEndOfDay()
+		periodStart := numberingPeriodStart(topic, startOfDay, config)
+
+		var lastNo int
+		if err := db.Model(&models.QueueSequence{}).
+			Where("topic_id = ? AND period_start = ?", topic.ID, periodStart).
+			Pluck("last_no", &lastNo).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue sequence")
+			return
+		}
+
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"next":        formatQueueNo(topic, lastNo+1),
+			"issued":      lastNo,
+			"periodStart": periodStart.Format("2006-01-02"),
+			"reset":       topic.NoReset,
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"src/helpers"
+	"src/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func validPriority(priority helpers.PRIORITY) bool {
+	_, ok := helpers.PRIORITY_RANK[priority]
+	return ok
+}
+
+func priorityScoreSQL(config models.Config, alias string) string {
+	column := func(name string) string {
+		if alias == "" {
+			return name
+		}
+		return alias + "." + name
+	}
+
+	priorities := make([]helpers.PRIORITY, 0, len(helpers.PRIORITY_RANK))
+	maxRank := 0
+	for priority, rank := range helpers.PRIORITY_RANK {
+		priorities = append(priorities, priority)
+		if rank > maxRank {
+			maxRank = rank
+		}
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		return helpers.PRIORITY_RANK[priorities[i]] > helpers.PRIORITY_RANK[priorities[j]]
+	})
+
+	var rank strings.Builder
+	rank.WriteString("CASE WHEN " + column("priority_from") + " > now() THEN 0")
+	for _, priority := range priorities {
+		rank.WriteString(fmt.Sprintf(" WHEN %s = '%s' THEN %d", column("priority"), priority, helpers.PRIORITY_RANK[priority]))
+	}
+	rank.WriteString(" ELSE 0 END")
+
+	if config.PriorityAgingMinutes <= 0 {
+		return rank.String()
+	}
+	return fmt.Sprintf("LEAST(%d, %s + FLOOR(EXTRACT(EPOCH FROM (now() - %s)) / %d))",
+		maxRank, rank.String(), column("created_at"), config.PriorityAgingMinutes*60)
+}
+
+func waitingOrder(config models.Config) string {
+	return priorityScoreSQL(config, "") + " DESC, order_at ASC, no ASC"
+}
+
+func SetQueuePriority(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		body := new(struct {
+			Priority helpers.PRIORITY `json:"priority"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || !validPriority(body.Priority) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid priority")
+			return
+		}
+
+		var queue models.Queue
+		if err := db.Preload("Topic").First(&queue, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if !canServeQueue(c, db, queue) {
+			return
+		}
+		if queue.Status != helpers.WAITING {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only waiting queues can change priority")
+			return
+		}
+
+		before := queue
+		if err := db.Model(&queue).Update("priority", body.Priority).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update queue priority")
+			return
+		}
+		if err := RecordQueueEvent(db, queue, helpers.QUEUE_EVENT_PRIORITY, &queue.Status, queueActor(c, db), map[string]interface{}{
+			"from": before.Priority,
+			"to":   body.Priority,
+		}); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to record queue event")
+			return
+		}
+
+		waiting, err := FindWaitingQueue(db, queue.Topic, queue.ID)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "updatePriority",
+			"data": map[string]interface{}{
+				"queue":   queue,
+				"waiting": waiting,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "priority", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"queue":   queue,
+			"waiting": waiting,
+		})
+	}
+}
+
+func activePriorityGrant(db *gorm.DB, email string) (helpers.PRIORITY, bool) {
+	var grant models.PriorityGrant
+	err := db.Where("LOWER(email) = LOWER(?) AND (expires_at IS NULL OR expires_at > ?)", email, time.Now()).
+		First(&grant).Error
+	if err != nil || !validPriority(grant.Priority) {
+		return helpers.PRIORITY_NORMAL, false
+	}
+	return grant.Priority, true
+}
+
+func GetPriorityGrants(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var grants []models.PriorityGrant
+		if err := db.Order("email ASC").Find(&grants).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch priority grants")
+			return
+		}
+		helpers.FormatSuccessResponse(c, grants)
+	}
+}
+
+func SavePriorityGrant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			Email     string           `json:"email"`
+			Priority  helpers.PRIORITY `json:"priority"`
+			Reason    *string          `json:"reason"`
+			ExpiresAt *time.Time       `json:"expiresAt"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || strings.TrimSpace(body.Email) == "" {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if !validPriority(body.Priority) || body.Priority == helpers.PRIORITY_NORMAL {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid priority '%v'", body.Priority))
+			return
+		}
+
+		grant := models.PriorityGrant{
+			Email:     strings.ToLower(strings.TrimSpace(body.Email)),
+			Priority:  body.Priority,
+			Reason:    body.Reason,
+			ExpiresAt: body.ExpiresAt,
+		}
+		var before *models.PriorityGrant
+		var existing models.PriorityGrant
+		if err := db.Where("email = ?", grant.Email).First(&existing).Error; err == nil {
+			before = &existing
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve priority grant")
+			return
+		}
+
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "email"}},
+			DoUpdates: clause.AssignmentColumns([]string{"priority", "reason", "expires_at"}),
+		}).Create(&grant).Error
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to save priority grant")
+			return
+		}
+
+		recordAudit(db, c, "save", "priority_grant", grant.Email, before, grant)
+		helpers.FormatSuccessResponse(c, grant)
+	}
+}
+
+func DeletePriorityGrant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var grant models.PriorityGrant
+		if err := db.First(&grant, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Priority grant not found")
+			return
+		}
+		if err := db.Delete(&grant).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to delete priority grant")
+			return
+		}
+
+		recordAudit(db, c, "delete", "priority_grant", grant.Email, grant, nil)
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Priority grant deleted successfully"})
+	}
+}
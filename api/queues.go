@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"src/helpers"
 	"src/middleware"
@@ -14,10 +13,11 @@ import (
 )
 
 type ReserveDTO struct {
-	Topic     int     `json:"topic" validate:"required"`
-	Note      *string `json:"note"`
-	FirstName *string `json:"firstName"`
-	LastName  *string `json:"lastName"`
+	Topic     int              `json:"topic" validate:"required"`
+	Note      *string          `json:"note"`
+	FirstName *string          `json:"firstName"`
+	LastName  *string          `json:"lastName"`
+	Members   []QueueMemberDTO `json:"members"`
 }
 
 func GetQueues(db *gorm.DB) gin.HandlerFunc {
@@ -39,10 +39,16 @@ func GetQueues(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		config, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+
 		var waitingQueues []models.Queue
 		if err := db.Preload("Topic").
-			Where("status = ? AND topic_id IN (SELECT topic_id FROM counter_topics WHERE counter_id = ?)", helpers.WAITING, counterID).
-			Order("created_at ASC, no ASC").
+			Where("status = ? AND (assigned_counter_id = ? OR (assigned_counter_id IS NULL AND topic_id IN (SELECT topic_id FROM counter_topics WHERE counter_id = ?)))", helpers.WAITING, counterID, counterID).
+			Order(waitingOrder(config)).
 			Find(&waitingQueues).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch waiting queues")
 			return
@@ -64,9 +70,12 @@ func GetStudentQueue(db *gorm.DB) gin.HandlerFunc {
 		startOfDay, endOfDay := helpers.GetStartAndEndOfDay()
 
 		var queue models.Queue
-		var topic models.Topic
-		err := db.Preload("Topic").
-			Where("firstname = ? AND lastname = ? AND created_at >= ? AND created_at < ? AND feedback = ?", firstName, lastName, startOfDay, endOfDay, false).
+		err := db.Preload("Topic").Preload("Members").
+			Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay).
+			Where(db.Where("firstname = ? AND lastname = ? AND feedback = ?", firstName, lastName, false).
+				Where("NOT EXISTS (?)", db.Model(&models.QueueMember{}).Select("1").Where("queue_members.queue_id = queues.id")).
+				Or("id IN (?)", db.Model(&models.QueueMember{}).Select("queue_id").
+					Where("firstname = ? AND lastname = ? AND feedback = ?", firstName, lastName, false))).
 			Order("created_at DESC, no DESC").First(&queue).Error
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -77,15 +86,21 @@ func GetStudentQueue(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		countWaitingAfterInProgress, err := FindWaitingQueue(db, int(topic.ID), int(queue.ID), topic.Code)
+		countWaitingAfterInProgress, err := FindWaitingQueue(db, queue.Topic, queue.ID)
 		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
 			return
 		}
+		eta, err := EstimateQueueEta(db, queue, countWaitingAfterInProgress)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to estimate waiting time")
+			return
+		}
 
 		helpers.FormatSuccessResponse(c, map[string]interface{}{
-			"queue":   queue,
-			"waiting": countWaitingAfterInProgress})
+			"queue":      queue,
+			"waiting":    countWaitingAfterInProgress,
+			"etaSeconds": eta})
 	}
 }
 
@@ -93,7 +108,7 @@ func GetCalledQueues(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var calledQueues []models.Queue
 		if err := db.Preload("Topic").
-			Where("status = ?", helpers.CALLED).
+			Where("status = ?", helpers.DONE).
 			Order("created_at DESC, no DESC").
 			Find(&calledQueues).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch waiting queues")
@@ -128,29 +143,28 @@ func CreateQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			return
 		}
 
-		startOfDay, endOfDay := helpers.GetStartAndEndOfDay()
-
-		var lastQueueNo string
-		err = db.Model(&models.Queue{}).
-			Where("topic_id = ? AND created_at >= ? AND created_at < ?", body.Topic, startOfDay, endOfDay).
-			Order("no DESC").Limit(1).Pluck("no", &lastQueueNo).Error
-		if err != nil && err != gorm.ErrRecordNotFound {
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve the last queue number")
-			return
-		}
-
-		var newQueueNo string
-		if lastQueueNo != "" {
-			var numPart int
-			_, err := fmt.Sscanf(lastQueueNo, topic.Code+"%03d", &numPart)
+		if body.FirstName != nil {
+			config, err := loadConfig(db)
 			if err != nil {
-				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to parse the last queue number")
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+				return
+			}
+			if !guestReservationAllowed(config, topic.GuestAccess) {
+				message, _ := json.Marshal(map[string]interface{}{
+					"event": "rejectQueue",
+					"data": map[string]interface{}{
+						"code":    helpers.GUEST_RESERVATION_DISABLED,
+						"topicId": topic.ID,
+					},
+				})
+				hub.broadcast <- message
+
+				helpers.FormatErrorResponse(c, http.StatusForbidden, map[string]interface{}{
+					"code":    helpers.GUEST_RESERVATION_DISABLED,
+					"message": "Reservations without a CMU account are closed for this topic",
+				})
 				return
 			}
-			numPart++
-			newQueueNo = fmt.Sprintf("%s%03d", topic.Code, numPart)
-		} else {
-			newQueueNo = fmt.Sprintf("%s001", topic.Code)
 		}
 
 		var note *string
@@ -162,11 +176,12 @@ func CreateQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 
 		var firstName, lastName string
 		var studentID *string
+		priority := helpers.PRIORITY_NORMAL
 		if body.FirstName != nil && body.LastName != nil {
 			firstName = *body.FirstName
 			lastName = *body.LastName
 		} else {
-			middleware.AuthRequired()
+			middleware.AuthRequired(db)
 			userClaims, ok := helpers.ExtractClaims(c)
 			if !ok {
 				return
@@ -186,63 +201,100 @@ func CreateQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 				helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid studentId in token")
 				return
 			}
+			if priorityClaim, ok := userClaims["priority"].(string); ok && validPriority(helpers.PRIORITY(priorityClaim)) {
+				priority = helpers.PRIORITY(priorityClaim)
+			}
 			studentID = &studentIDClaim
 			firstName = firstNameClaim
 			lastName = lastNameClaim
 		}
 
+		startOfDay, _ := helpers.GetStartAndEndOfDay()
 		queue := models.Queue{
-			No:        newQueueNo,
-			StudentID: studentID,
-			Firstname: firstName,
-			Lastname:  lastName,
-			TopicID:   body.Topic,
-			Note:      note,
+			StudentID:   studentID,
+			Firstname:   firstName,
+			Lastname:    lastName,
+			TopicID:     body.Topic,
+			Note:        note,
+			Priority:    priority,
+			ServiceDate: startOfDay,
+		}
+		members, err := buildQueueMembers(queue, body.Members)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
 		}
+		queue.Members = members
 
-		if err := db.Create(&queue).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := checkTicketLimits(tx, queue); err != nil {
+				return err
+			}
+			if err := checkTopicAvailability(tx, topic); err != nil {
+				return err
+			}
+			queueNo, err := allocateQueueNo(tx, topic, startOfDay)
+			if err != nil {
+				return err
+			}
+			queue.No = queueNo
+			if err := tx.Create(&queue).Error; err != nil {
+				return err
+			}
+			return RecordQueueEvent(tx, queue, helpers.QUEUE_EVENT_CREATED, nil, queueActor(c, tx), nil)
+		})
+		if ticketLimitErrorResponse(c, err) || topicUnavailableErrorResponse(c, err) {
+			return
+		}
+		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to create queue")
 			return
 		}
 
-		countWaitingAfterInProgress, err := FindWaitingQueue(db, body.Topic, queue.ID, topic.Code)
+		countWaitingAfterInProgress, err := FindWaitingQueue(db, topic, queue.ID)
 		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
 			return
 		}
 
-		err = db.Model(&queue).Preload("Topic").First(&queue).Error
+		err = db.Model(&queue).Preload("Topic").Preload("Members").First(&queue).Error
 		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue details")
 			return
 		}
+		eta, err := EstimateQueueEta(db, queue, countWaitingAfterInProgress)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to estimate waiting time")
+			return
+		}
 		message, _ := json.Marshal(map[string]interface{}{
 			"event": "addQueue",
 			"data": map[string]interface{}{
-				"queue":   queue,
-				"waiting": countWaitingAfterInProgress,
+				"queue":      queue,
+				"waiting":    countWaitingAfterInProgress,
+				"etaSeconds": eta,
 			},
 		})
 		hub.broadcast <- message
 
 		if body.FirstName != nil && body.LastName != nil {
-			tokenString, err := generateJWTToken(body, true)
+			tokens, err := generateJWTToken(db, body, helpers.GUEST, nil, nil)
 			if err != nil {
 				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate JWT token")
 				return
 			}
 
-			helpers.FormatSuccessResponse(c, map[string]interface{}{
-				"token":   tokenString,
-				"queue":   queue,
-				"waiting": countWaitingAfterInProgress,
-			})
+			tokens["queue"] = queue
+			tokens["waiting"] = countWaitingAfterInProgress
+			tokens["etaSeconds"] = eta
+			helpers.FormatSuccessResponse(c, tokens)
 			return
 		}
 
 		helpers.FormatSuccessResponse(c, map[string]interface{}{
-			"queue":   queue,
-			"waiting": countWaitingAfterInProgress,
+			"queue":      queue,
+			"waiting":    countWaitingAfterInProgress,
+			"etaSeconds": eta,
 		})
 	}
 }
@@ -258,28 +310,36 @@ func UpdateQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
-		tx := db.Begin()
-		if err := tx.Model(&models.Queue{}).Where("id = ?", body.Current).Update("status", helpers.CALLED).Error; err != nil {
-			tx.Rollback()
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update current queue to CALLED")
-			return
-		}
-		if err := tx.Model(&models.Queue{}).Where("id = ?", id).Updates(map[string]interface{}{
-			"status":     helpers.IN_PROGRESS,
-			"counter_id": body.Counter,
-		}).Error; err != nil {
-			tx.Rollback()
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update queue to IN_PROGRESS")
+		if !middleware.CanAccessCounter(c, body.Counter) {
 			return
 		}
+		actor := queueActor(c, db)
 		var currentQueue models.Queue
-		if err := tx.Preload("Topic").First(&currentQueue, id).Error; err != nil {
-			tx.Rollback()
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch current queue")
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if body.Current != 0 {
+				var previous models.Queue
+				if err := tx.First(&previous, body.Current).Error; err != nil {
+					return err
+				}
+				if finishableAt(previous, body.Counter) {
+					if err := FinishQueue(tx, &previous, actor); err != nil {
+						return err
+					}
+				}
+			}
+			if err := tx.First(&currentQueue, id).Error; err != nil {
+				return err
+			}
+			return TransitionQueue(tx, &currentQueue, helpers.CALLING, map[string]interface{}{
+				"counter_id": body.Counter,
+			}, actor)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
 			return
 		}
-		if err := tx.Commit().Error; err != nil {
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to commit transaction")
+		if err != nil {
+			transitionErrorResponse(c, err, "Failed to call queue")
 			return
 		}
 
@@ -292,6 +352,7 @@ func UpdateQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "call", "queue", currentQueue.ID, nil, currentQueue)
 		helpers.FormatSuccessResponse(c, currentQueue)
 	}
 }
@@ -304,8 +365,12 @@ func DeleteQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
 			return
 		}
-		if err := db.Delete(&models.Queue{}, id).Error; err != nil {
-			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to delete queue")
+		if !canServeQueue(c, db, queue) {
+			return
+		}
+		before := queue
+		if err := TransitionQueue(db, &queue, helpers.CANCELLED, nil, queueActor(c, db)); err != nil {
+			transitionErrorResponse(c, err, "Failed to cancel queue")
 			return
 		}
 
@@ -315,7 +380,8 @@ func DeleteQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
-		helpers.FormatSuccessResponse(c, map[string]string{"message": "Queue deleted successfully"})
+		recordAudit(db, c, "cancel", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Queue cancelled successfully"})
 	}
 }
 
@@ -332,6 +398,35 @@ func UpdateQueueFeedback(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		member, err := claimedMember(c, db, queue.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue member")
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) && !ownsQueue(userClaims, queue) {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "This queue belongs to someone else")
+			return
+		}
+		if err == nil {
+			if err := db.Model(&member).Update("feedback", true).Error; err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update member feedback")
+				return
+			}
+			var pending int64
+			if err := db.Model(&models.QueueMember{}).Where("queue_id = ? AND feedback = ?", queue.ID, false).Count(&pending).Error; err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count pending feedback")
+				return
+			}
+			if pending > 0 {
+				helpers.FormatSuccessResponse(c, map[string]interface{}{"message": "Queue updated successfully", "pendingMembers": pending})
+				return
+			}
+		}
+
 		if err := db.Model(&queue).Update("feedback", true).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update queue feedback")
 			return
@@ -340,12 +435,55 @@ func UpdateQueueFeedback(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func FindWaitingQueue(db *gorm.DB, topicID int, queueID int, topicCode string) (int, error) {
+func FindWaitingQueue(db *gorm.DB, topic models.Topic, queueID int) (int, error) {
+	config, err := loadConfig(db)
+	if err != nil {
+		return 0, err
+	}
+	score := priorityScoreSQL(config, "q")
+
 	var count int64
-	if err := db.Model(&models.Queue{}).
-		Where("topic_id = ? AND status IN ? AND id != ? AND no LIKE ?", topicID, []string{string(helpers.WAITING), string(helpers.IN_PROGRESS)}, queueID, topicCode+"%").
-		Count(&count).Error; err != nil {
+	err = db.Raw(`
+		SELECT COUNT(*) FROM queues q
+		CROSS JOIN (SELECT `+priorityScoreSQL(config, "me")+` AS score, me.order_at, me.no FROM queues me WHERE me.id = ?) self
+		WHERE q.topic_id = ? AND q.id != ? AND q.no LIKE ?
+		AND (q.status IN ? OR (q.status = ? AND (`+score+` > self.score OR (`+score+` = self.score AND (q.order_at, q.no) < (self.order_at, self.no)))))
+	`, queueID, topic.ID, queueID, queueNoPrefix(topic)+"%", []helpers.STATUS{helpers.CALLING, helpers.SERVING}, helpers.WAITING).Scan(&count).Error
+	if err != nil {
 		return 0, err
 	}
 	return int(count), nil
 }
+
+func canServeQueue(c *gin.Context, db *gorm.DB, queue models.Queue) bool {
+	userClaims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return false
+	}
+	if userClaims["role"] == helpers.ADMIN {
+		return true
+	}
+	counterID, ok := middleware.ClaimCounterID(userClaims)
+	if !ok {
+		helpers.FormatErrorResponse(c, http.StatusForbidden, "You are not assigned to a counter")
+		return false
+	}
+	if queue.CounterID != nil && *queue.CounterID == counterID {
+		return true
+	}
+	if queue.AssignedCounterID != nil && *queue.AssignedCounterID == counterID {
+		return true
+	}
+	var count int64
+	if err := db.Model(&models.CounterTopic{}).
+		Where("counter_id = ? AND topic_id = ?", counterID, queue.TopicID).
+		Count(&count).Error; err != nil {
+		helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check counter topics")
+		return false
+	}
+	if count == 0 {
+		helpers.FormatErrorResponse(c, http.StatusForbidden, "This queue is not served by your counter")
+		return false
+	}
+	return true
+}
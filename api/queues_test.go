@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestUpdateQueueSkipsUnfinishableCurrent(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+	counter := seedCounter(t, db, "1", topic)
+
+	cases := []struct {
+		name      string
+		status    helpers.STATUS
+		counterID *int
+	}{
+		{"cancelled", helpers.CANCELLED, &counter.ID},
+		{"no-show", helpers.NO_SHOW, &counter.ID},
+		{"requeued", helpers.WAITING, nil},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			current := seedQueue(t, db, models.Queue{No: fmt.Sprintf("C%02d", i), TopicID: topic.ID, Status: tc.status, CounterID: tc.counterID})
+			next := seedQueue(t, db, models.Queue{No: fmt.Sprintf("N%02d", i), TopicID: topic.ID, Status: helpers.WAITING})
+
+			res := performRequest(UpdateQueue(db, hub), http.MethodPut, "/queue", fmt.Sprintf(`{"counter":%d,"current":%d}`, counter.ID, current.ID),
+				adminClaims(), gin.Params{{Key: "id", Value: fmt.Sprint(next.ID)}})
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+			}
+
+			var reloaded models.Queue
+			db.First(&reloaded, current.ID)
+			if reloaded.Status != tc.status {
+				t.Errorf("current queue changed from %s to %s", tc.status, reloaded.Status)
+			}
+			db.First(&reloaded, next.ID)
+			if reloaded.Status != helpers.CALLING {
+				t.Errorf("next queue is %s, want CALLING", reloaded.Status)
+			}
+			db.Model(&models.Queue{}).Where("id = ?", next.ID).Update("status", helpers.DONE)
+		})
+	}
+}
+
+func TestUpdateQueueFinishesCalledCurrent(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+	counter := seedCounter(t, db, "1", topic)
+	current := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.CALLING, CounterID: &counter.ID})
+	next := seedQueue(t, db, models.Queue{No: "A002", TopicID: topic.ID, Status: helpers.WAITING})
+
+	res := performRequest(UpdateQueue(db, hub), http.MethodPut, "/queue", fmt.Sprintf(`{"counter":%d,"current":%d}`, counter.ID, current.ID),
+		adminClaims(), gin.Params{{Key: "id", Value: fmt.Sprint(next.ID)}})
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+	var reloaded models.Queue
+	db.First(&reloaded, current.ID)
+	if reloaded.Status != helpers.DONE {
+		t.Errorf("current queue is %s, want DONE", reloaded.Status)
+	}
+}
+
+func TestGroupHolderFeedbackReleasesTicket(t *testing.T) {
+	db := openTestDB(t)
+	topic := seedTopic(t, db, "A")
+	queue := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.DONE, Firstname: "Holder", Lastname: "One"})
+	for _, name := range []string{"Holder", "Member"} {
+		if err := db.Create(&models.QueueMember{QueueID: queue.ID, Firstname: name, Lastname: "One"}).Error; err != nil {
+			t.Fatalf("failed to seed member: %v", err)
+		}
+	}
+
+	res := performRequest(UpdateQueueFeedback(db), http.MethodPut, "/queue/feedback", "",
+		jwt.MapClaims{"role": "Student", "firstName": "Holder", "lastName": "One"}, gin.Params{{Key: "id", Value: fmt.Sprint(queue.ID)}})
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+
+	ticketFor := func(firstName string) int {
+		res := performRequest(GetStudentQueue(db), http.MethodGet, "/queue/student?firstName="+firstName+"&lastName=One", "", nil, nil)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+		}
+		var body struct {
+			Data struct {
+				Queue struct {
+					ID int `json:"id"`
+				} `json:"queue"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body.Data.Queue.ID
+	}
+	if id := ticketFor("Holder"); id != 0 {
+		t.Errorf("holder still gets queue %d after giving feedback", id)
+	}
+	if id := ticketFor("Member"); id != queue.ID {
+		t.Errorf("pending member gets queue %d, want %d", id, queue.ID)
+	}
+}
+
+func TestCreateQueueConcurrentNumbersAreUnique(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+
+	const reservations = 40
+	handler := func(c *gin.Context) {
+		c.Set("parsedBody", ReserveDTO{Topic: topic.ID})
+		CreateQueue(db, hub)(c)
+	}
+	var wg sync.WaitGroup
+	failures := make(chan string, reservations)
+	for i := 0; i < reservations; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res := performRequest(handler, http.MethodPost, "/queue", "", studentClaims(fmt.Sprintf("6500%05d", i)), nil)
+			if res.Code != http.StatusOK {
+				failures <- fmt.Sprintf("%d: %s", res.Code, res.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(failures)
+	for failure := range failures {
+		t.Errorf("reservation failed with %s", failure)
+	}
+
+	db.First(&topic, topic.ID)
+	var numbers []string
+	db.Model(&models.Queue{}).Where("topic_id = ?", topic.ID).Order("no ASC").Pluck("no", &numbers)
+	if len(numbers) != reservations {
+		t.Fatalf("created %d queues, want %d", len(numbers), reservations)
+	}
+	for i, no := range numbers {
+		if want := formatQueueNo(topic, i+1); no != want {
+			t.Fatalf("queue %d is numbered %s, want %s", i, no, want)
+		}
+	}
+}
+
+func TestUpdateQueueFeedbackRejectsStrangers(t *testing.T) {
+	db := openTestDB(t)
+	topic := seedTopic(t, db, "A")
+	holderID := "650000001"
+	queue := seedQueue(t, db, models.Queue{No: "A001", TopicID: topic.ID, Status: helpers.DONE, StudentID: &holderID})
+	if err := db.Create(&models.QueueMember{QueueID: queue.ID, StudentID: &holderID, Firstname: "Student", Lastname: holderID}).Error; err != nil {
+		t.Fatalf("failed to seed member: %v", err)
+	}
+
+	res := performRequest(UpdateQueueFeedback(db), http.MethodPut, "/queue/feedback", "",
+		studentClaims("650000002"), gin.Params{{Key: "id", Value: fmt.Sprint(queue.ID)}})
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", res.Code, res.Body.String())
+	}
+	var reloaded models.Queue
+	db.First(&reloaded, queue.ID)
+	if reloaded.Feedback {
+		t.Error("a stranger closed feedback for the queue")
+	}
+}
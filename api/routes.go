@@ -2,11 +2,15 @@ package api
 
 import (
 	"net/http"
+	"os"
 	"src/helpers"
 	"src/middleware"
 	"src/models"
+	"src/storage"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -64,13 +68,16 @@ func SaveSubscription(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, hub *Hub) {
+func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, hub *Hub, store storage.Storage) {
+	identityProvider := NewIdentityProvider()
 
-	r.POST("/authentication", Authentication(db))
+	r.POST("/authentication", Authentication(db, identityProvider))
+	r.POST("/authentication/refresh", RefreshToken(db))
 	r.GET("/config", GetConfig(db))
 
 	r.GET("/counter", GetCounters(db))
 	r.GET("/topic", GetTopics(db))
+	r.GET("/topic/:id/slots", GetTopicSlots(db))
 
 	condition := func(c *gin.Context) bool {
 		var body ReserveDTO
@@ -81,41 +88,127 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, hub *Hub) {
 		c.Set("parsedBody", body)
 		return body.FirstName == nil
 	}
-	r.POST("/queue", ConditionalMiddleware(middleware.AuthRequired(), condition), CreateQueue(db, hub))
+	queueLimiter := NewQueueRateLimiter(db)
+	r.POST("/queue", ConditionalMiddleware(middleware.AuthRequired(db), condition), queueLimiter.Limit(queueRateLimitKeys), CreateQueue(db, hub))
 
 	protected := r.Group("/")
-	protected.Use(middleware.AuthRequired())
+	protected.Use(middleware.AuthRequired(db))
 	{
-		protected.POST("/subscribe", SaveSubscription(db))
-		protected.POST("/send-notification", SendNotificationTrigger(db, hub))
-
-		protected.GET("/user", GetUserInfo(db))
-
-		protected.PUT("/config/login-not-cmu", SetLoginNotCmu(db, hub))
-		protected.PUT("/config/audio", SetAudio(db, hub))
-
-		protected.POST("/counter", CreateCounter(db, hub))
-		protected.PUT("/counter/:id", UpdateCounter(db, hub))
-		protected.DELETE("/counter/:id", DeleteCounter(db, hub))
-
-		protected.POST("/topic", CreateTopic(db, hub))
-		protected.PUT("/topic/:id", UpdateTopic(db, hub))
-		protected.DELETE("/topic/:id", DeleteTopic(db, hub))
+		protected.POST("/logout", Logout(db))
+
+		protected.GET("/users", middleware.RequirePermission(helpers.MANAGE_USERS), GetUsers(db))
+		protected.POST("/users", middleware.RequirePermission(helpers.MANAGE_USERS), CreateUser(db))
+		protected.PUT("/users/:id/role", middleware.RequirePermission(helpers.MANAGE_USERS), UpdateUserRole(db))
+		protected.PUT("/users/:id/status", middleware.RequirePermission(helpers.MANAGE_USERS), UpdateUserStatus(db))
+		protected.DELETE("/users/:id/counter", middleware.RequirePermission(helpers.MANAGE_USERS), UnassignUserCounter(db, hub))
+		protected.DELETE("/users/:id/sessions", middleware.RequirePermission(helpers.MANAGE_USERS), RevokeUserSessions(db))
+
+		protected.GET("/audit-logs", middleware.RequirePermission(helpers.VIEW_AUDIT_LOGS), GetAuditLogs(db))
+		protected.GET("/audit-logs/export", middleware.RequirePermission(helpers.VIEW_AUDIT_LOGS), ExportAuditLogs(db))
+
+		protected.GET("/priority-grants", middleware.RequirePermission(helpers.MANAGE_PRIORITY), GetPriorityGrants(db))
+		protected.POST("/priority-grants", middleware.RequirePermission(helpers.MANAGE_PRIORITY), SavePriorityGrant(db))
+		protected.DELETE("/priority-grants/:id", middleware.RequirePermission(helpers.MANAGE_PRIORITY), DeletePriorityGrant(db))
+
+		protected.GET("/rate-limit/stats", middleware.RequirePermission(helpers.VIEW_RATE_LIMITS), GetRateLimitStats(queueLimiter))
+
+		protected.POST("/subscribe", middleware.RequirePermission(helpers.SUBSCRIBE), SaveSubscription(db))
+		protected.POST("/send-notification", middleware.RequirePermission(helpers.SEND_NOTIFICATION), SendNotificationTrigger(db, hub))
+
+		protected.GET("/user", middleware.RequirePermission(helpers.VIEW_PROFILE), GetUserInfo(db))
+
+		protected.PUT("/config/login-not-cmu", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetLoginNotCmu(db, hub))
+		protected.PUT("/config/audio", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetAudio(db, hub))
+		protected.PUT("/config/term-starts", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetTermStarts(db, hub))
+		protected.PUT("/config/priority-aging", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetPriorityAging(db, hub))
+		protected.PUT("/config/routing-strategy", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetRoutingStrategy(db, hub))
+		protected.PUT("/config/ticket-limits", middleware.RequirePermission(helpers.MANAGE_CONFIG), SetTicketLimits(db, hub))
+
+		protected.POST("/counter", middleware.RequirePermission(helpers.MANAGE_COUNTERS), CreateCounter(db, hub))
+		protected.PUT("/counter/:id", middleware.RequirePermission(helpers.OPERATE_COUNTER), UpdateCounter(db, hub))
+		protected.DELETE("/counter/:id", middleware.RequirePermission(helpers.MANAGE_COUNTERS), DeleteCounter(db, hub))
+		protected.POST("/counter/:id/call-next", middleware.RequirePermission(helpers.SERVE_QUEUES), CallNextQueue(db, hub))
+
+		protected.POST("/topic", middleware.RequirePermission(helpers.MANAGE_TOPICS), CreateTopic(db, hub))
+		protected.PUT("/topic/:id", middleware.RequirePermission(helpers.MANAGE_TOPICS), UpdateTopic(db, hub))
+		protected.DELETE("/topic/:id", middleware.RequirePermission(helpers.MANAGE_TOPICS), DeleteTopic(db, hub))
+		protected.GET("/topic/:id/next-number", middleware.RequirePermission(helpers.MANAGE_TOPICS), PreviewQueueNo(db))
+
+		protected.GET("/queue", middleware.RequirePermission(helpers.VIEW_QUEUES), GetQueues(db))
+		protected.GET("/queue/student", middleware.RequirePermission(helpers.VIEW_OWN_QUEUE), GetStudentQueue(db))
+		protected.GET("/queue/called", middleware.RequirePermission(helpers.VIEW_QUEUES), GetCalledQueues(db))
+		protected.PUT("/queue/feedback/:id", middleware.RequirePermission(helpers.GIVE_FEEDBACK), UpdateQueueFeedback(db))
+		protected.POST("/queue/:id/cancel", middleware.RequirePermission(helpers.VIEW_OWN_QUEUE), CancelOwnQueue(db, hub))
+		protected.POST("/queue/:id/confirm", middleware.RequirePermission(helpers.VIEW_OWN_QUEUE), ConfirmOwnQueue(db, hub))
+		protected.POST("/queue/:id/defer", middleware.RequirePermission(helpers.VIEW_OWN_QUEUE), DeferOwnQueue(db, hub))
+		protected.PUT("/queue/:id", middleware.RequirePermission(helpers.SERVE_QUEUES), UpdateQueue(db, hub))
+		protected.PUT("/queue/:id/state", middleware.RequirePermission(helpers.SERVE_QUEUES), UpdateQueueState(db, hub))
+		protected.PUT("/queue/:id/priority", middleware.RequirePermission(helpers.SERVE_QUEUES), SetQueuePriority(db, hub))
+		protected.POST("/queue/:id/hold", middleware.RequirePermission(helpers.SERVE_QUEUES), HoldQueue(db, hub))
+		protected.POST("/queue/:id/resume", middleware.RequirePermission(helpers.SERVE_QUEUES), ResumeQueue(db, hub))
+		protected.POST("/queue/:id/transfer", middleware.RequirePermission(helpers.SERVE_QUEUES), TransferQueue(db, hub))
+		protected.GET("/queue/:id/transfers", middleware.RequirePermission(helpers.VIEW_QUEUES), GetQueueTransfers(db))
+		protected.GET("/queue/:id/timeline", middleware.RequirePermission(helpers.VIEW_QUEUES), GetQueueTimeline(db))
+		protected.GET("/queue/:id/durations", middleware.RequirePermission(helpers.VIEW_QUEUES), GetQueueDurations(db))
+		protected.DELETE("/queue/:id", middleware.RequirePermission(helpers.SERVE_QUEUES), DeleteQueue(db, hub))
+		protected.POST("/queue/:id/attachments", middleware.RequirePermission(helpers.VIEW_OWN_QUEUE), UploadAttachment(db, hub, store))
+		protected.GET("/queue/:id/attachments", middleware.RequirePermission(helpers.VIEW_ATTACHMENTS), GetQueueAttachments(db))
+		protected.GET("/attachments/:id", middleware.RequirePermission(helpers.VIEW_ATTACHMENTS), DownloadAttachment(db, store))
+		protected.DELETE("/attachments/:id", middleware.RequirePermission(helpers.VIEW_ATTACHMENTS), DeleteAttachment(db, hub, store))
+
+		protected.GET("/appointment", middleware.RequirePermission(helpers.BOOK_APPOINTMENT), GetMyAppointments(db))
+		protected.GET("/appointment/all", middleware.RequirePermission(helpers.VIEW_QUEUES), GetAppointments(db))
+		protected.POST("/appointment", middleware.RequirePermission(helpers.BOOK_APPOINTMENT), CreateAppointment(db, hub))
+		protected.PUT("/appointment/:id", middleware.RequirePermission(helpers.BOOK_APPOINTMENT), RescheduleAppointment(db, hub))
+		protected.DELETE("/appointment/:id", middleware.RequirePermission(helpers.BOOK_APPOINTMENT), CancelAppointment(db, hub))
+		protected.POST("/appointment/:id/check-in", middleware.RequirePermission(helpers.BOOK_APPOINTMENT), CheckInAppointment(db, hub))
+
+		protected.GET("/feedback", middleware.RequirePermission(helpers.VIEW_FEEDBACK), GetFeedbackByUser(db))
+		protected.POST("/feedback", middleware.RequirePermission(helpers.GIVE_FEEDBACK), CreateFeedback(db))
+
+		protected.GET("/noti-schedule", middleware.RequirePermission(helpers.MANAGE_SCHEDULES), GetNotiSchedule(db))
+		protected.POST("/noti-schedule", middleware.RequirePermission(helpers.MANAGE_SCHEDULES), CreateNotiSchedule(db))
+		protected.PUT("/noti-schedule/:id", middleware.RequirePermission(helpers.MANAGE_SCHEDULES), UpdateNotiSchedule(db))
+		protected.DELETE("/noti-schedule/:id", middleware.RequirePermission(helpers.MANAGE_SCHEDULES), DeleteNotiSchedule(db))
+	}
+}
 
-		protected.GET("/queue", GetQueues(db))
-		protected.GET("/queue/student", GetStudentQueue(db))
-		protected.GET("/queue/called", GetCalledQueues(db))
-		protected.PUT("/queue/feedback/:id", UpdateQueueFeedback(db))
-		protected.PUT("/queue/:id", UpdateQueue(db, hub))
-		protected.DELETE("/queue/:id", DeleteQueue(db, hub))
+func NewQueueRateLimiter(db *gorm.DB) *middleware.RateLimiter {
+	var store middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if strings.ToLower(os.Getenv("RATE_LIMIT_STORE")) == "postgres" {
+		store = middleware.NewPostgresRateLimitStore(db)
+	}
+	return middleware.NewRateLimiter(store,
+		middleware.RateLimitRuleFromEnv("ip", "RATE_LIMIT_QUEUE_PER_IP", "20/1m"),
+		middleware.RateLimitRuleFromEnv("student", "RATE_LIMIT_QUEUE_PER_STUDENT", "3/10m"),
+		middleware.RateLimitRuleFromEnv("guest", "RATE_LIMIT_QUEUE_PER_GUEST", "3/10m"),
+	)
+}
 
-		protected.GET("/feedback", GetFeedbackByUser(db))
-		protected.POST("/feedback", CreateFeedback(db))
+func queueRateLimitKeys(c *gin.Context) []middleware.RateLimitKey {
+	keys := []middleware.RateLimitKey{{Scope: "ip", Value: c.ClientIP()}}
+	if claims, ok := c.Get("claims"); ok {
+		if userClaims, ok := claims.(jwt.MapClaims); ok {
+			studentID, _ := userClaims["studentId"].(string)
+			keys = append(keys, middleware.RateLimitKey{Scope: "student", Value: studentID})
+		}
+	}
+	if body, ok := c.Get("parsedBody"); ok {
+		if reserve, ok := body.(ReserveDTO); ok && reserve.FirstName != nil && reserve.LastName != nil {
+			keys = append(keys, middleware.RateLimitKey{Scope: "guest", Value: strings.TrimSpace(*reserve.FirstName) + " " + strings.TrimSpace(*reserve.LastName)})
+		}
+	}
+	return keys
+}
 
-		protected.GET("/noti-schedule", GetNotiSchedule(db))
-		protected.POST("/noti-schedule", CreateNotiSchedule(db))
-		protected.PUT("/noti-schedule/:id", UpdateNotiSchedule(db))
-		protected.DELETE("/noti-schedule/:id", DeleteNotiSchedule(db))
+func GetRateLimitStats(limiter *middleware.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := limiter.Stats()
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve rate limit stats")
+			return
+		}
+		helpers.FormatSuccessResponse(c, stats)
 	}
 }
 
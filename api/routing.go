@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"src/helpers"
+	"src/middleware"
+	"src/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNoWaitingQueue = errors.New("no waiting queue for counter")
+
+func validRoutingStrategy(strategy helpers.ROUTING_STRATEGY) bool {
+	switch strategy {
+	case helpers.ROUTING_FIFO, helpers.ROUTING_ROUND_ROBIN, helpers.ROUTING_WEIGHTED, helpers.ROUTING_PRIORITY_FIRST:
+		return true
+	}
+	return false
+}
+
+func eligibleQueues(tx *gorm.DB, counterID int) *gorm.DB {
+	return tx.Model(&models.Queue{}).
+		Where("queues.status = ?", helpers.WAITING).
+		Where("queues.assigned_counter_id = ? OR (queues.assigned_counter_id IS NULL AND queues.topic_id IN (?))",
+			counterID, tx.Model(&models.CounterTopic{}).Select("topic_id").Where("counter_id = ?", counterID))
+}
+
+func waitingTopicIDs(tx *gorm.DB, counterID int) ([]int, error) {
+	var topicIDs []int
+	err := eligibleQueues(tx, counterID).Distinct("queues.topic_id").Order("queues.topic_id ASC").Pluck("queues.topic_id", &topicIDs).Error
+	return topicIDs, err
+}
+
+func roundRobinTopic(tx *gorm.DB, counterID int, topicIDs []int) (int, error) {
+	var last models.Queue
+	err := tx.Where("counter_id = ? AND called_at IS NOT NULL", counterID).Order("called_at DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	for _, topicID := range topicIDs {
+		if topicID > last.TopicID {
+			return topicID, nil
+		}
+	}
+	return topicIDs[0], nil
+}
+
+func weightedTopic(tx *gorm.DB, counterID int, topicIDs []int) (int, error) {
+	var rows []struct {
+		ID     int
+		Weight int
+		Served int
+	}
+	startOfDay, _ := helpers.GetStartAndEndOfDay()
+	err := tx.Table("topics").
+		Select("topics.id, topics.weight, COUNT(queues.id) AS served").
+		Joins("LEFT JOIN queues ON queues.topic_id = topics.id AND queues.counter_id = ? AND queues.called_at >= ?", counterID, startOfDay).
+		Where("topics.id IN ?", topicIDs).
+		Group("topics.id").
+		Order("topics.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	picked := topicIDs[0]
+	best := -1.0
+	for _, row := range rows {
+		weight := row.Weight
+		if weight < 1 {
+			weight = 1
+		}
+		share := float64(row.Served) / float64(weight)
+		if best < 0 || share < best {
+			best = share
+			picked = row.ID
+		}
+	}
+	return picked, nil
+}
+
+func pickNextQueue(tx *gorm.DB, counterID int, config models.Config) (models.Queue, error) {
+	var next models.Queue
+	query := eligibleQueues(tx, counterID)
+	switch config.RoutingStrategy {
+	case helpers.ROUTING_FIFO:
+		query = query.Order("order_at ASC, no ASC")
+	case helpers.ROUTING_ROUND_ROBIN, helpers.ROUTING_WEIGHTED:
+		topicIDs, err := waitingTopicIDs(tx, counterID)
+		if err != nil {
+			return next, err
+		}
+		if len(topicIDs) == 0 {
+			return next, errNoWaitingQueue
+		}
+		var topicID int
+		if config.RoutingStrategy == helpers.ROUTING_ROUND_ROBIN {
+			topicID, err = roundRobinTopic(tx, counterID, topicIDs)
+		} else {
+			topicID, err = weightedTopic(tx, counterID, topicIDs)
+		}
+		if err != nil {
+			return next, err
+		}
+		query = query.Where("queues.topic_id = ?", topicID).Order(waitingOrder(config))
+	default:
+		query = query.Order(waitingOrder(config))
+	}
+
+	err := query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return next, errNoWaitingQueue
+	}
+	return next, err
+}
+
+func CallNextQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		counterID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid counter id")
+			return
+		}
+		if !middleware.CanAccessCounter(c, counterID) {
+			return
+		}
+
+		actor := queueActor(c, db)
+		var finished []models.Queue
+		var next models.Queue
+		empty := false
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var counter models.Counter
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, counterID).Error; err != nil {
+				return err
+			}
+			config, err := loadConfig(tx)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Where("counter_id = ? AND status IN ?", counterID, []helpers.STATUS{helpers.CALLING, helpers.SERVING}).
+				Find(&finished).Error; err != nil {
+				return err
+			}
+			for i := range finished {
+				if err := FinishQueue(tx, &finished[i], actor); err != nil {
+					return err
+				}
+			}
+
+			next, err = pickNextQueue(tx, counterID, config)
+			if errors.Is(err, errNoWaitingQueue) {
+				empty = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return TransitionQueue(tx, &next, helpers.CALLING, map[string]interface{}{
+				"counter_id": counterID,
+			}, actor)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Counter not found")
+			return
+		}
+		if err != nil {
+			transitionErrorResponse(c, err, "Failed to call next queue")
+			return
+		}
+
+		if empty {
+			for _, queue := range finished {
+				message, _ := json.Marshal(map[string]interface{}{
+					"event": "updateQueueState",
+					"data": map[string]interface{}{
+						"queue": queue,
+					},
+				})
+				hub.broadcast <- message
+			}
+			c.Status(http.StatusNoContent)
+			return
+		}
+
+		called := 0
+		if len(finished) > 0 {
+			called = finished[0].ID
+		}
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "updateQueue",
+			"data": map[string]interface{}{
+				"current": next,
+				"called":  called,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "call", "queue", next.ID, nil, next)
+		helpers.FormatSuccessResponse(c, next)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCallNextConcurrentCountersPickDistinctQueues(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	topic := seedTopic(t, db, "A")
+
+	const counters = 8
+	const waiting = 5
+	for i := 1; i <= waiting; i++ {
+		seedQueue(t, db, models.Queue{No: fmt.Sprintf("A%03d", i), TopicID: topic.ID, Status: helpers.WAITING})
+	}
+	var counterIDs []int
+	for i := 1; i <= counters; i++ {
+		counterIDs = append(counterIDs, seedCounter(t, db, fmt.Sprint(i), topic).ID)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	picked := map[int]int{}
+	empty := 0
+	for _, counterID := range counterIDs {
+		wg.Add(1)
+		go func(counterID int) {
+			defer wg.Done()
+			res := performRequest(CallNextQueue(db, hub), http.MethodPost, "/counter/call-next", "", adminClaims(),
+				gin.Params{{Key: "id", Value: fmt.Sprint(counterID)}})
+			mu.Lock()
+			defer mu.Unlock()
+			switch res.Code {
+			case http.StatusNoContent:
+				empty++
+			case http.StatusOK:
+				var body struct {
+					Data struct {
+						ID int `json:"id"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+					t.Errorf("failed to decode response: %v", err)
+					return
+				}
+				if other, ok := picked[body.Data.ID]; ok {
+					t.Errorf("queue %d called by counters %d and %d", body.Data.ID, other, counterID)
+				}
+				picked[body.Data.ID] = counterID
+			default:
+				t.Errorf("counter %d got %d: %s", counterID, res.Code, res.Body.String())
+			}
+		}(counterID)
+	}
+	wg.Wait()
+
+	if len(picked) != waiting || empty != counters-waiting {
+		t.Errorf("picked %d queues with %d empty calls, want %d and %d", len(picked), empty, waiting, counters-waiting)
+	}
+	for queueID, counterID := range picked {
+		var queue models.Queue
+		db.First(&queue, queueID)
+		if queue.Status != helpers.CALLING || queue.CounterID == nil || *queue.CounterID != counterID {
+			t.Errorf("queue %s is %s at %v, want CALLING at counter %d", queue.No, queue.Status, queue.CounterID, counterID)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+const maxQueueDefers = 2
+
+func findOwnQueue(c *gin.Context, db *gorm.DB) (models.Queue, bool) {
+	userClaims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return models.Queue{}, false
+	}
+
+	var queue models.Queue
+	if err := db.Preload("Topic").First(&queue, c.Param("id")).Error; err != nil {
+		helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+		return queue, false
+	}
+
+	if !ownsQueue(userClaims, queue) {
+		helpers.FormatErrorResponse(c, http.StatusForbidden, "This queue belongs to someone else")
+		return queue, false
+	}
+	return queue, true
+}
+
+func ownsQueue(userClaims jwt.MapClaims, queue models.Queue) bool {
+	studentID, _ := userClaims["studentId"].(string)
+	firstName, _ := userClaims["firstName"].(string)
+	lastName, _ := userClaims["lastName"].(string)
+	if studentID != "" {
+		return queue.StudentID != nil && *queue.StudentID == studentID
+	}
+	return queue.StudentID == nil && queue.Firstname == firstName && queue.Lastname == lastName
+}
+
+func broadcastOwnQueue(db *gorm.DB, hub *Hub, event string, queue models.Queue) (int, error) {
+	waiting, err := FindWaitingQueue(db, queue.Topic, queue.ID)
+	if err != nil {
+		return 0, err
+	}
+	message, _ := json.Marshal(map[string]interface{}{
+		"event": event,
+		"data": map[string]interface{}{
+			"queue":   queue,
+			"waiting": waiting,
+		},
+	})
+	hub.broadcast <- message
+	return waiting, nil
+}
+
+func CancelOwnQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queue, ok := findOwnQueue(c, db)
+		if !ok {
+			return
+		}
+		if err := TransitionQueue(db, &queue, helpers.CANCELLED, nil, queueActor(c, db)); err != nil {
+			transitionErrorResponse(c, err, "Failed to cancel queue")
+			return
+		}
+
+		if _, err := broadcastOwnQueue(db, hub, "cancelQueue", queue); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+		helpers.FormatSuccessResponse(c, queue)
+	}
+}
+
+func ConfirmOwnQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queue, ok := findOwnQueue(c, db)
+		if !ok {
+			return
+		}
+		if queue.Status != helpers.WAITING && queue.Status != helpers.CALLING {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only waiting or called queues can be confirmed")
+			return
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"confirmed_at": now}
+		if queue.Status == helpers.CALLING {
+			updates["last_called_at"] = now
+		}
+		result := db.Model(&models.Queue{}).Where("id = ? AND status = ?", queue.ID, queue.Status).Updates(updates)
+		if result.Error != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to confirm queue")
+			return
+		}
+		if result.RowsAffected == 0 {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "The queue changed state, please refresh")
+			return
+		}
+		if err := db.Preload("Topic").First(&queue, queue.ID).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue details")
+			return
+		}
+		if err := RecordQueueEvent(db, queue, helpers.QUEUE_EVENT_CONFIRM, &queue.Status, queueActor(c, db), nil); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to record queue event")
+			return
+		}
+
+		waiting, err := broadcastOwnQueue(db, hub, "confirmQueue", queue)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"queue":   queue,
+			"waiting": waiting,
+		})
+	}
+}
+
+func DeferOwnQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(struct {
+			Positions int `json:"positions"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || body.Positions < 1 || body.Positions > 20 {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "positions must be between 1 and 20")
+			return
+		}
+		queue, ok := findOwnQueue(c, db)
+		if !ok {
+			return
+		}
+		if queue.Status != helpers.WAITING {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "Only waiting queues can be deferred")
+			return
+		}
+		if queue.DeferCount >= maxQueueDefers {
+			helpers.FormatErrorResponse(c, http.StatusConflict, "This queue has already been deferred the maximum number of times")
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			config, err := loadConfig(tx)
+			if err != nil {
+				return err
+			}
+			var waitingIDs []int
+			if err := tx.Model(&models.Queue{}).
+				Where("topic_id = ? AND status = ?", queue.TopicID, helpers.WAITING).
+				Order(waitingOrder(config)).
+				Pluck("id", &waitingIDs).Error; err != nil {
+				return err
+			}
+			ahead := 0
+			for i, id := range waitingIDs {
+				if id == queue.ID {
+					ahead = i
+					break
+				}
+			}
+
+			orderAt, err := requeueOrderAt(tx, queue, ahead+body.Positions)
+			if err != nil {
+				return err
+			}
+			result := tx.Model(&models.Queue{}).Where("id = ? AND status = ?", queue.ID, helpers.WAITING).Updates(map[string]interface{}{
+				"order_at":    orderAt,
+				"defer_count": gorm.Expr("defer_count + 1"),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrIllegalTransition
+			}
+			if err := tx.Preload("Topic").First(&queue, queue.ID).Error; err != nil {
+				return err
+			}
+			return RecordQueueEvent(tx, queue, helpers.QUEUE_EVENT_DEFER, &queue.Status, queueActor(c, tx), map[string]interface{}{
+				"positions": body.Positions,
+			})
+		})
+		if err != nil {
+			transitionErrorResponse(c, err, "Failed to defer queue")
+			return
+		}
+
+		waiting, err := broadcastOwnQueue(db, hub, "deferQueue", queue)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"queue":   queue,
+			"waiting": waiting,
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func sessionSubject(claims jwt.MapClaims) string {
+	if email, ok := claims["email"].(string); ok && email != "" {
+		return email
+	}
+	firstName, _ := claims["firstName"].(string)
+	lastName, _ := claims["lastName"].(string)
+	return "guest:" + firstName + " " + lastName
+}
+
+func issueTokens(db *gorm.DB, claims jwt.MapClaims, userID *int) (map[string]interface{}, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		Subject:          sessionSubject(claims),
+		Claims:           string(rawClaims),
+		RefreshTokenHash: hashRefreshSecret(secret),
+		ExpiresAt:        now.Add(helpers.RefreshTokenTTL()),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	claims["sid"] = sessionID
+	accessToken, err := helpers.SignToken(claims, helpers.AccessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"token":        accessToken,
+		"refreshToken": sessionID + "." + secret,
+		"expiresIn":    int(helpers.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+func revokeUserSessions(tx *gorm.DB, userIDs ...int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Session{}).
+		Where("user_id IN ? AND revoked_at IS NULL", userIDs).
+		Update("revoked_at", time.Now()).Error
+}
+
+func DisconnectRevokedSessions(db *gorm.DB, hub *Hub, since time.Time) (time.Time, error) {
+	checkedAt := time.Now()
+	var sessionIDs []string
+	if err := db.Model(&models.Session{}).Where("revoked_at >= ?", since).Pluck("id", &sessionIDs).Error; err != nil {
+		return since, fmt.Errorf("failed to fetch revoked sessions: %v", err)
+	}
+	if len(sessionIDs) > 0 {
+		hub.DisconnectSessions(sessionIDs)
+	}
+	return checkedAt, nil
+}
+
+func RefreshToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || body.RefreshToken == "" {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid refresh token")
+			return
+		}
+		sessionID, secret, found := strings.Cut(body.RefreshToken, ".")
+		if !found {
+			helpers.FormatErrorResponse(c, http.StatusUnauthorized, "Malformed refresh token")
+			return
+		}
+
+		var session models.Session
+		if err := db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				helpers.FormatErrorResponse(c, http.StatusUnauthorized, "Session not found")
+			} else {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve session")
+			}
+			return
+		}
+		if session.RevokedAt != nil {
+			helpers.FormatErrorResponse(c, http.StatusUnauthorized, "Session has been revoked")
+			return
+		}
+		if time.Now().After(session.ExpiresAt) {
+			helpers.FormatErrorResponse(c, http.StatusUnauthorized, "Refresh token has expired")
+			return
+		}
+
+		newSecret, err := randomToken(32)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate refresh token")
+			return
+		}
+		now := time.Now()
+		result := db.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, hashRefreshSecret(secret)).
+			Updates(map[string]interface{}{
+				"refresh_token_hash": hashRefreshSecret(newSecret),
+				"expires_at":         now.Add(helpers.RefreshTokenTTL()),
+				"last_used_at":       now,
+			})
+		if result.Error != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to rotate refresh token")
+			return
+		}
+		if result.RowsAffected == 0 {
+			db.Model(&models.Session{}).Where("id = ?", session.ID).Update("revoked_at", now)
+			helpers.FormatErrorResponse(c, http.StatusUnauthorized, "Refresh token reuse detected, session revoked")
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		if err := json.Unmarshal([]byte(session.Claims), &claims); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to restore session claims")
+			return
+		}
+		claims["sid"] = session.ID
+		accessToken, err := helpers.SignToken(claims, helpers.AccessTokenTTL())
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to generate JWT token")
+			return
+		}
+
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"token":        accessToken,
+			"refreshToken": session.ID + "." + newSecret,
+			"expiresIn":    int(helpers.AccessTokenTTL().Seconds()),
+		})
+	}
+}
+
+func Logout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClaims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		sessionID, _ := userClaims["sid"].(string)
+		if err := db.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session")
+			return
+		}
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Logged out successfully"})
+	}
+}
+
+func RevokeUserSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err := revokeUserSessions(db, user.ID); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions")
+			return
+		}
+		recordAudit(db, c, "revokeSessions", "user", user.ID, nil, nil)
+		helpers.FormatSuccessResponse(c, map[string]string{"message": "Sessions revoked successfully"})
+	}
+}
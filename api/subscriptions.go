@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -62,6 +63,7 @@ func SendNotificationTrigger(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		body := new(struct {
 			No        *string `json:"no"`
+			QueueID   *int    `json:"queueId"`
 			Counter   *string `json:"counter"`
 			FirstName string  `json:"firstName"`
 			LastName  string  `json:"lastName"`
@@ -78,11 +80,32 @@ func SendNotificationTrigger(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		}
 
 		var queueData map[string]interface{}
+		var recalled *models.Queue
 		if body.No != nil {
 			queueData = map[string]interface{}{
 				"no":      body.No,
 				"counter": body.Counter,
 			}
+			queue, err := findRecalledQueue(db, body.QueueID, *body.No, body.FirstName, body.LastName)
+			if err == nil {
+				if err := recordRecall(db, &queue); err != nil {
+					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to record recall")
+					return
+				}
+				if err := RecordQueueEvent(db, queue, helpers.QUEUE_EVENT_RECALL, &queue.Status, queueActor(c, db), map[string]interface{}{
+					"recallCount": queue.RecallCount,
+				}); err != nil {
+					helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to record queue event")
+					return
+				}
+				queueData["id"] = queue.ID
+				queueData["recallCount"] = queue.RecallCount
+				queueData["lastCalledAt"] = queue.LastCalledAt
+				recalled = &queue
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue")
+				return
+			}
 		}
 
 		if err := SendPushNotification(db, hub, body.Message, userIdentifier, queueData); err != nil {
@@ -90,7 +113,11 @@ func SendNotificationTrigger(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if recalled != nil {
+			NotifyQueueMembers(db, hub, body.Message, *recalled)
+		}
 
+		recordAudit(db, c, "send", "notification", body.FirstName+" "+body.LastName, nil, body)
 		helpers.FormatSuccessResponse(c, map[string]string{"status": "notification sent"})
 	}
 }
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"src/helpers"
+	"src/middleware"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+type QueueActor struct {
+	UserID    *int
+	Email     *string
+	CounterID *int
+}
+
+var SystemActor = QueueActor{}
+
+func queueActor(c *gin.Context, db *gorm.DB) QueueActor {
+	var actor QueueActor
+	claims, ok := c.Get("claims")
+	if !ok {
+		return actor
+	}
+	userClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return actor
+	}
+	if email, ok := userClaims["email"].(string); ok && email != "" {
+		actor.Email = &email
+		switch userClaims["role"] {
+		case helpers.ADMIN, helpers.COUNTER_STAFF, helpers.VIEWER:
+			var userID int
+			if err := db.Model(&models.User{}).Where("email = ?", email).Pluck("id", &userID).Error; err == nil && userID != 0 {
+				actor.UserID = &userID
+			}
+		}
+	}
+	if counterID, ok := middleware.ClaimCounterID(userClaims); ok {
+		actor.CounterID = &counterID
+	}
+	return actor
+}
+
+func RecordQueueEvent(tx *gorm.DB, queue models.Queue, eventType helpers.QUEUE_EVENT, from *helpers.STATUS, actor QueueActor, data interface{}) error {
+	counterID := queue.CounterID
+	if counterID == nil {
+		counterID = actor.CounterID
+	}
+	return tx.Create(&models.QueueEvent{
+		QueueID:    queue.ID,
+		Type:       eventType,
+		FromStatus: from,
+		ToStatus:   queue.Status,
+		CounterID:  counterID,
+		UserID:     actor.UserID,
+		ActorEmail: actor.Email,
+		Data:       toAuditJSON(data),
+	}).Error
+}
+
+func secondsBetween(from, to *time.Time) *float64 {
+	if from == nil || to == nil {
+		return nil
+	}
+	seconds := to.Sub(*from).Seconds()
+	return &seconds
+}
+
+func queueDurations(queue models.Queue, events []models.QueueEvent) map[string]interface{} {
+	createdAt := queue.CreatedAt
+	var calledAt, servedAt, finishedAt *time.Time
+	for i := range events {
+		event := &events[i]
+		if event.Type == helpers.QUEUE_EVENT_CREATED {
+			createdAt = event.CreatedAt
+			continue
+		}
+		if event.Type != helpers.QUEUE_EVENT_TRANSITION {
+			continue
+		}
+		switch event.ToStatus {
+		case helpers.CALLING:
+			if calledAt == nil {
+				calledAt = &event.CreatedAt
+			}
+		case helpers.SERVING:
+			servedAt = &event.CreatedAt
+		case helpers.DONE, helpers.CANCELLED, helpers.NO_SHOW, helpers.EXPIRED:
+			finishedAt = &event.CreatedAt
+		}
+	}
+
+	return map[string]interface{}{
+		"createdAt":          createdAt,
+		"calledAt":           calledAt,
+		"servedAt":           servedAt,
+		"finishedAt":         finishedAt,
+		"waitSeconds":        secondsBetween(&createdAt, calledAt),
+		"callToServeSeconds": secondsBetween(calledAt, servedAt),
+		"serviceSeconds":     secondsBetween(calledAt, finishedAt),
+		"totalSeconds":       secondsBetween(&createdAt, finishedAt),
+		"recalls":            queue.RecallCount,
+		"state":              queue.Status,
+	}
+}
+
+func loadQueueTimeline(c *gin.Context, db *gorm.DB) (models.Queue, []models.QueueEvent, bool) {
+	var queue models.Queue
+	if err := db.Preload("Topic").First(&queue, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return queue, nil, false
+		}
+		helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue")
+		return queue, nil, false
+	}
+
+	var events []models.QueueEvent
+	if err := db.Where("queue_id = ?", queue.ID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch queue events")
+		return queue, nil, false
+	}
+	return queue, events, true
+}
+
+func GetQueueTimeline(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queue, events, ok := loadQueueTimeline(c, db)
+		if !ok {
+			return
+		}
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"queue":     queue,
+			"events":    events,
+			"durations": queueDurations(queue, events),
+		})
+	}
+}
+
+func GetQueueDurations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queue, events, ok := loadQueueTimeline(c, db)
+		if !ok {
+			return
+		}
+		helpers.FormatSuccessResponse(c, queueDurations(queue, events))
+	}
+}
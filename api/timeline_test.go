@@ -0,0 +1,34 @@
+package api
+
+import (
+	"src/helpers"
+	"src/models"
+	"testing"
+	"time"
+)
+
+func TestQueueDurationsMeasureServiceFromCall(t *testing.T) {
+	created := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	waiting := helpers.WAITING
+	calling := helpers.CALLING
+	serving := helpers.SERVING
+	events := []models.QueueEvent{
+		{Type: helpers.QUEUE_EVENT_CREATED, ToStatus: helpers.WAITING, CreatedAt: created},
+		{Type: helpers.QUEUE_EVENT_TRANSITION, FromStatus: &waiting, ToStatus: helpers.CALLING, CreatedAt: created.Add(10 * time.Minute)},
+		{Type: helpers.QUEUE_EVENT_TRANSITION, FromStatus: &calling, ToStatus: helpers.SERVING, CreatedAt: created.Add(14 * time.Minute)},
+		{Type: helpers.QUEUE_EVENT_TRANSITION, FromStatus: &serving, ToStatus: helpers.DONE, CreatedAt: created.Add(14 * time.Minute)},
+	}
+
+	durations := queueDurations(models.Queue{CreatedAt: created, Status: helpers.DONE}, events)
+	want := map[string]float64{
+		"waitSeconds":    600,
+		"serviceSeconds": 240,
+		"totalSeconds":   840,
+	}
+	for key, expected := range want {
+		got, ok := durations[key].(*float64)
+		if !ok || got == nil || *got != expected {
+			t.Errorf("%s = %v, want %v", key, got, expected)
+		}
+	}
+}
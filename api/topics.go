@@ -14,36 +14,155 @@ import (
 func GetTopics(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var topics []struct {
-			ID      int    `json:"id"`
-			TopicTH string `json:"topicTH"`
-			TopicEN string `json:"topicEN"`
-			Code    string `json:"code"`
-			Waiting int    `json:"waiting"`
+			ID           int                  `json:"id"`
+			TopicTH      string               `json:"topicTH"`
+			TopicEN      string               `json:"topicEN"`
+			Code         string               `json:"code"`
+			GuestAccess  helpers.GUEST_ACCESS `json:"guestAccess"`
+			GuestAllowed bool                 `json:"guestAllowed" gorm:"-"`
+			Waiting      int                  `json:"waiting"`
+			OpenCounters int                  `json:"openCounters" gorm:"-"`
+			EtaSeconds   *float64             `json:"etaSeconds" gorm:"-"`
+			Availability topicAvailability    `json:"availability" gorm:"-"`
 		}
 		if err := db.Table("topics").
-			Select("topics.id, topics.topic_th, topics.topic_en, topics.code, COUNT(queues.id) AS waiting").
-			Joins("LEFT JOIN queues ON queues.topic_id = topics.id AND queues.status IN (?, ?)", helpers.WAITING, helpers.IN_PROGRESS).
+			Select("topics.id, topics.topic_th, topics.topic_en, topics.code, topics.guest_access, COUNT(queues.id) AS waiting").
+			Joins("LEFT JOIN queues ON queues.topic_id = topics.id AND queues.status IN ?", []helpers.STATUS{helpers.WAITING, helpers.CALLING, helpers.SERVING}).
 			Group("topics.id").
 			Order("topics.id ASC").
 			Scan(&topics).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch topics with waiting queues")
 			return
 		}
+
+		config, err := loadConfig(db)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve config")
+			return
+		}
+		var policies []models.Topic
+		if err := db.Find(&policies).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch topics")
+			return
+		}
+		policyByID := map[int]models.Topic{}
+		for _, policy := range policies {
+			policyByID[policy.ID] = policy
+		}
+		waiting := map[int]int{}
+		for _, topic := range topics {
+			waiting[topic.ID] = topic.Waiting
+		}
+		estimates, err := EstimateTopicEtas(db, waiting)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to estimate waiting time")
+			return
+		}
+		for i := range topics {
+			topics[i].GuestAllowed = guestReservationAllowed(config, topics[i].GuestAccess)
+			estimate := estimates[topics[i].ID]
+			topics[i].OpenCounters = estimate.OpenCounters
+			topics[i].EtaSeconds = estimate.EtaSeconds
+			availability, err := TopicAvailability(db, policyByID[topics[i].ID], topics[i].Waiting)
+			if err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check topic availability")
+				return
+			}
+			topics[i].Availability = availability
+		}
 		helpers.FormatSuccessResponse(c, topics)
 	}
 }
 
+func guestReservationAllowed(config models.Config, access helpers.GUEST_ACCESS) bool {
+	switch access {
+	case helpers.GUEST_ACCESS_OPEN:
+		return true
+	case helpers.GUEST_ACCESS_CMU_ONLY:
+		return false
+	}
+	return config.LoginNotCmu
+}
+
+func validGuestAccess(access helpers.GUEST_ACCESS) bool {
+	switch access {
+	case helpers.GUEST_ACCESS_DEFAULT, helpers.GUEST_ACCESS_OPEN, helpers.GUEST_ACCESS_CMU_ONLY:
+		return true
+	}
+	return false
+}
+
+func newTopic(topicTH, topicEN, code string) models.Topic {
+	return models.Topic{
+		TopicTH:     topicTH,
+		TopicEN:     topicEN,
+		Code:        code,
+		GuestAccess: helpers.GUEST_ACCESS_DEFAULT,
+		NoWidth:     3,
+		NoStart:     1,
+		NoStep:      1,
+		NoReset:     helpers.RESET_DAILY,
+
+		SlotMinutes:         0,
+		SlotCapacity:        1,
+		BookingLeadMinutes:  60,
+		BookingDays:         14,
+		CancelCutoffMinutes: 60,
+		CheckInGraceMinutes: 15,
+
+		NoShowAction:       helpers.NO_SHOW_ACTION_NONE,
+		NoShowMaxRecalls:   3,
+		NoShowGraceMinutes: 5,
+		RequeueOffset:      3,
+
+		Weight:          1,
+		CutoffAtClosing: true,
+	}
+}
+
 func CreateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var body struct {
-			TopicTH string `json:"topicTH"`
-			TopicEN string `json:"topicEN"`
-			Code    string `json:"code"`
+			TopicTH     string               `json:"topicTH"`
+			TopicEN     string               `json:"topicEN"`
+			Code        string               `json:"code"`
+			GuestAccess helpers.GUEST_ACCESS `json:"guestAccess"`
+			NoPrefix    *string              `json:"noPrefix"`
+			NoWidth     *int                 `json:"noWidth"`
+			NoStart     *int                 `json:"noStart"`
+			NoStep      *int                 `json:"noStep"`
+			NoReset     helpers.RESET_PERIOD `json:"noReset"`
+
+			SlotMinutes         *int `json:"slotMinutes"`
+			SlotCapacity        *int `json:"slotCapacity"`
+			BookingLeadMinutes  *int `json:"bookingLeadMinutes"`
+			BookingDays         *int `json:"bookingDays"`
+			CancelCutoffMinutes *int `json:"cancelCutoffMinutes"`
+			CheckInGraceMinutes *int `json:"checkInGraceMinutes"`
+
+			NoShowAction       helpers.NO_SHOW_ACTION `json:"noShowAction"`
+			NoShowMaxRecalls   *int                   `json:"noShowMaxRecalls"`
+			NoShowGraceMinutes *int                   `json:"noShowGraceMinutes"`
+			RequeueOffset      *int                   `json:"requeueOffset"`
+
+			Weight *int `json:"weight"`
+
+			IssueOpensAt    *string `json:"issueOpensAt"`
+			IssueClosesAt   *string `json:"issueClosesAt"`
+			DailyCap        *int    `json:"dailyCap"`
+			CutoffAtClosing *bool   `json:"cutoffAtClosing"`
 		}
 		if err := c.ShouldBindJSON(&body); err != nil {
 			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
 			return
 		}
+		if body.GuestAccess == "" {
+			body.GuestAccess = helpers.GUEST_ACCESS_DEFAULT
+		}
+		if !validGuestAccess(body.GuestAccess) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid guestAccess")
+			return
+		}
 
 		var existingTopic models.Topic
 		if err := db.Where("code = ?", body.Code).First(&existingTopic).Error; err == nil {
@@ -54,10 +173,85 @@ func CreateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			return
 		}
 
-		topic := models.Topic{
-			TopicTH: body.TopicTH,
-			TopicEN: body.TopicEN,
-			Code:    body.Code,
+		topic := newTopic(body.TopicTH, body.TopicEN, body.Code)
+		topic.GuestAccess = body.GuestAccess
+		topic.NoPrefix = body.NoPrefix
+		if body.NoWidth != nil {
+			topic.NoWidth = *body.NoWidth
+		}
+		if body.NoStart != nil {
+			topic.NoStart = *body.NoStart
+		}
+		if body.NoStep != nil {
+			topic.NoStep = *body.NoStep
+		}
+		if body.NoReset != "" {
+			topic.NoReset = body.NoReset
+		}
+		if err := validNumberingPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.SlotMinutes != nil {
+			topic.SlotMinutes = *body.SlotMinutes
+		}
+		if body.SlotCapacity != nil {
+			topic.SlotCapacity = *body.SlotCapacity
+		}
+		if body.BookingLeadMinutes != nil {
+			topic.BookingLeadMinutes = *body.BookingLeadMinutes
+		}
+		if body.BookingDays != nil {
+			topic.BookingDays = *body.BookingDays
+		}
+		if body.CancelCutoffMinutes != nil {
+			topic.CancelCutoffMinutes = *body.CancelCutoffMinutes
+		}
+		if body.CheckInGraceMinutes != nil {
+			topic.CheckInGraceMinutes = *body.CheckInGraceMinutes
+		}
+		if err := validSlotPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.NoShowAction != "" {
+			topic.NoShowAction = body.NoShowAction
+		}
+		if body.NoShowMaxRecalls != nil {
+			topic.NoShowMaxRecalls = *body.NoShowMaxRecalls
+		}
+		if body.NoShowGraceMinutes != nil {
+			topic.NoShowGraceMinutes = *body.NoShowGraceMinutes
+		}
+		if body.RequeueOffset != nil {
+			topic.RequeueOffset = *body.RequeueOffset
+		}
+		if err := validNoShowPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.Weight != nil {
+			if *body.Weight < 1 {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "weight must be at least 1")
+				return
+			}
+			topic.Weight = *body.Weight
+		}
+		if body.IssueOpensAt != nil && *body.IssueOpensAt != "" {
+			topic.IssueOpensAt = body.IssueOpensAt
+		}
+		if body.IssueClosesAt != nil && *body.IssueClosesAt != "" {
+			topic.IssueClosesAt = body.IssueClosesAt
+		}
+		if body.DailyCap != nil {
+			topic.DailyCap = *body.DailyCap
+		}
+		if body.CutoffAtClosing != nil {
+			topic.CutoffAtClosing = *body.CutoffAtClosing
+		}
+		if err := validIssuancePolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
 		}
 		if err := db.Create(&topic).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to create topic")
@@ -70,6 +264,7 @@ func CreateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "create", "topic", topic.ID, nil, topic)
 		helpers.FormatSuccessResponse(c, topic)
 	}
 }
@@ -78,9 +273,34 @@ func UpdateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		var body struct {
-			TopicTH *string `json:"topicTH"`
-			TopicEN *string `json:"topicEN"`
-			Code    *string `json:"code"`
+			TopicTH     *string               `json:"topicTH"`
+			TopicEN     *string               `json:"topicEN"`
+			Code        *string               `json:"code"`
+			GuestAccess *helpers.GUEST_ACCESS `json:"guestAccess"`
+			NoPrefix    *string               `json:"noPrefix"`
+			NoWidth     *int                  `json:"noWidth"`
+			NoStart     *int                  `json:"noStart"`
+			NoStep      *int                  `json:"noStep"`
+			NoReset     *helpers.RESET_PERIOD `json:"noReset"`
+
+			SlotMinutes         *int `json:"slotMinutes"`
+			SlotCapacity        *int `json:"slotCapacity"`
+			BookingLeadMinutes  *int `json:"bookingLeadMinutes"`
+			BookingDays         *int `json:"bookingDays"`
+			CancelCutoffMinutes *int `json:"cancelCutoffMinutes"`
+			CheckInGraceMinutes *int `json:"checkInGraceMinutes"`
+
+			NoShowAction       *helpers.NO_SHOW_ACTION `json:"noShowAction"`
+			NoShowMaxRecalls   *int                    `json:"noShowMaxRecalls"`
+			NoShowGraceMinutes *int                    `json:"noShowGraceMinutes"`
+			RequeueOffset      *int                    `json:"requeueOffset"`
+
+			Weight *int `json:"weight"`
+
+			IssueOpensAt    *string `json:"issueOpensAt"`
+			IssueClosesAt   *string `json:"issueClosesAt"`
+			DailyCap        *int    `json:"dailyCap"`
+			CutoffAtClosing *bool   `json:"cutoffAtClosing"`
 		}
 		if err := c.ShouldBindJSON(&body); err != nil {
 			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
@@ -92,6 +312,7 @@ func UpdateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
 			return
 		}
+		before := topic
 
 		if body.Code != nil {
 			var existingTopic models.Topic
@@ -107,6 +328,102 @@ func UpdateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		if body.TopicEN != nil {
 			topic.TopicEN = *body.TopicEN
 		}
+		if body.GuestAccess != nil {
+			if !validGuestAccess(*body.GuestAccess) {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid guestAccess")
+				return
+			}
+			topic.GuestAccess = *body.GuestAccess
+		}
+		if body.NoPrefix != nil {
+			topic.NoPrefix = body.NoPrefix
+			if *body.NoPrefix == "" {
+				topic.NoPrefix = nil
+			}
+		}
+		if body.NoWidth != nil {
+			topic.NoWidth = *body.NoWidth
+		}
+		if body.NoStart != nil {
+			topic.NoStart = *body.NoStart
+		}
+		if body.NoStep != nil {
+			topic.NoStep = *body.NoStep
+		}
+		if body.NoReset != nil {
+			topic.NoReset = *body.NoReset
+		}
+		if err := validNumberingPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.SlotMinutes != nil {
+			topic.SlotMinutes = *body.SlotMinutes
+		}
+		if body.SlotCapacity != nil {
+			topic.SlotCapacity = *body.SlotCapacity
+		}
+		if body.BookingLeadMinutes != nil {
+			topic.BookingLeadMinutes = *body.BookingLeadMinutes
+		}
+		if body.BookingDays != nil {
+			topic.BookingDays = *body.BookingDays
+		}
+		if body.CancelCutoffMinutes != nil {
+			topic.CancelCutoffMinutes = *body.CancelCutoffMinutes
+		}
+		if body.CheckInGraceMinutes != nil {
+			topic.CheckInGraceMinutes = *body.CheckInGraceMinutes
+		}
+		if err := validSlotPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.NoShowAction != nil {
+			topic.NoShowAction = *body.NoShowAction
+		}
+		if body.NoShowMaxRecalls != nil {
+			topic.NoShowMaxRecalls = *body.NoShowMaxRecalls
+		}
+		if body.NoShowGraceMinutes != nil {
+			topic.NoShowGraceMinutes = *body.NoShowGraceMinutes
+		}
+		if body.RequeueOffset != nil {
+			topic.RequeueOffset = *body.RequeueOffset
+		}
+		if err := validNoShowPolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if body.Weight != nil {
+			if *body.Weight < 1 {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "weight must be at least 1")
+				return
+			}
+			topic.Weight = *body.Weight
+		}
+		if body.IssueOpensAt != nil {
+			topic.IssueOpensAt = body.IssueOpensAt
+			if *body.IssueOpensAt == "" {
+				topic.IssueOpensAt = nil
+			}
+		}
+		if body.IssueClosesAt != nil {
+			topic.IssueClosesAt = body.IssueClosesAt
+			if *body.IssueClosesAt == "" {
+				topic.IssueClosesAt = nil
+			}
+		}
+		if body.DailyCap != nil {
+			topic.DailyCap = *body.DailyCap
+		}
+		if body.CutoffAtClosing != nil {
+			topic.CutoffAtClosing = *body.CutoffAtClosing
+		}
+		if err := validIssuancePolicy(topic); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		if err := db.Save(&topic).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update topic")
@@ -119,6 +436,7 @@ func UpdateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "update", "topic", topic.ID, before, topic)
 		helpers.FormatSuccessResponse(c, topic)
 	}
 }
@@ -126,6 +444,11 @@ func UpdateTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 func DeleteTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
+		var topic models.Topic
+		if err := db.First(&topic, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
+			return
+		}
 		if err := db.Delete(&models.Topic{}, id).Error; err != nil {
 			helpers.FormatErrorResponse(c, http.StatusNotFound, "Topic not found")
 			return
@@ -137,6 +460,7 @@ func DeleteTopic(db *gorm.DB, hub *Hub) gin.HandlerFunc {
 		})
 		hub.broadcast <- message
 
+		recordAudit(db, c, "delete", "topic", topic.ID, topic, nil)
 		helpers.FormatSuccessResponse(c, map[string]string{"message": "Topic deleted successfully"})
 	}
 }
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"src/models"
+	"testing"
+)
+
+func TestCreateTopicAcceptsPolicyFields(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+
+	res := performRequest(CreateTopic(db, hub), http.MethodPost, "/topic",
+		`{"topicTH":"A","topicEN":"A","code":"A","slotMinutes":30,"slotCapacity":2,"bookingLeadMinutes":0,"noShowAction":"REQUEUE","noShowMaxRecalls":1,"requeueOffset":0,"weight":4,"issueOpensAt":"08:30","issueClosesAt":"16:00","dailyCap":50,"cutoffAtClosing":false}`,
+		adminClaims(), nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+
+	var topic models.Topic
+	db.Where("code = ?", "A").First(&topic)
+	if topic.SlotMinutes != 30 || topic.SlotCapacity != 2 || topic.BookingLeadMinutes != 0 || topic.BookingDays != 14 {
+		t.Errorf("slot policy stored as %d/%d/%d/%d, want 30/2/0/14", topic.SlotMinutes, topic.SlotCapacity, topic.BookingLeadMinutes, topic.BookingDays)
+	}
+	if topic.NoShowAction != "REQUEUE" || topic.NoShowMaxRecalls != 1 || topic.RequeueOffset != 0 || topic.NoShowGraceMinutes != 5 {
+		t.Errorf("no-show policy stored as %s/%d/%d/%d, want REQUEUE/1/0/5", topic.NoShowAction, topic.NoShowMaxRecalls, topic.RequeueOffset, topic.NoShowGraceMinutes)
+	}
+	if topic.Weight != 4 {
+		t.Errorf("weight stored as %d, want 4", topic.Weight)
+	}
+	if topic.IssueOpensAt == nil || topic.IssueClosesAt == nil || topic.DailyCap != 50 || topic.CutoffAtClosing {
+		t.Errorf("issuance policy stored as %v-%v cap %d cutoff %v", topic.IssueOpensAt, topic.IssueClosesAt, topic.DailyCap, topic.CutoffAtClosing)
+	}
+}
+
+func TestCreateTopicRejectsInvalidSlotPolicy(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+
+	res := performRequest(CreateTopic(db, hub), http.MethodPost, "/topic",
+		`{"topicTH":"A","topicEN":"A","code":"A","slotCapacity":0}`, adminClaims(), nil)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", res.Code, res.Body.String())
+	}
+	var count int64
+	db.Model(&models.Topic{}).Count(&count)
+	if count != 0 {
+		t.Errorf("created %d topics, want none", count)
+	}
+}
+
+func TestCreateTopicRejectsInvalidIssuanceWindow(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+
+	res := performRequest(CreateTopic(db, hub), http.MethodPost, "/topic",
+		`{"topicTH":"A","topicEN":"A","code":"A","issueOpensAt":"16:00","issueClosesAt":"08:30"}`, adminClaims(), nil)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", res.Code, res.Body.String())
+	}
+}
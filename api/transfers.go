@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TransferQueue(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		body := new(struct {
+			TopicID      *int    `json:"topicId"`
+			CounterID    *int    `json:"counterId"`
+			KeepPosition bool    `json:"keepPosition"`
+			Reason       *string `json:"reason"`
+		})
+		if err := c.ShouldBindJSON(body); err != nil || (body.TopicID == nil && body.CounterID == nil) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body, topicId or counterId is required")
+			return
+		}
+
+		var queue models.Queue
+		if err := db.Preload("Topic").First(&queue, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if !canServeQueue(c, db, queue) {
+			return
+		}
+		if queue.Status != helpers.WAITING && !CanTransition(queue.Status, helpers.WAITING) {
+			helpers.FormatErrorResponse(c, http.StatusConflict, fmt.Sprintf("A %s queue cannot be transferred", queue.Status))
+			return
+		}
+
+		target := queue.Topic
+		if body.TopicID != nil && *body.TopicID != queue.TopicID {
+			if err := db.First(&target, *body.TopicID).Error; err != nil {
+				helpers.FormatErrorResponse(c, http.StatusNotFound, "Target topic not found")
+				return
+			}
+		}
+		if body.CounterID != nil {
+			var count int64
+			if err := db.Model(&models.CounterTopic{}).
+				Where("counter_id = ? AND topic_id = ?", *body.CounterID, target.ID).
+				Count(&count).Error; err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check counter topics")
+				return
+			}
+			if count == 0 {
+				helpers.FormatErrorResponse(c, http.StatusBadRequest, "The target counter does not serve the target topic")
+				return
+			}
+		}
+
+		before := queue
+		transfer := models.QueueTransfer{
+			QueueID:       queue.ID,
+			FromTopicID:   queue.TopicID,
+			ToTopicID:     target.ID,
+			FromCounterID: queue.CounterID,
+			ToCounterID:   body.CounterID,
+			FromNo:        queue.No,
+			ToNo:          queue.No,
+			KeepPosition:  body.KeepPosition,
+			Reason:        body.Reason,
+		}
+		if userClaims, ok := helpers.ExtractClaims(c); ok {
+			if email, ok := userClaims["email"].(string); ok && email != "" {
+				transfer.ActorEmail = &email
+			}
+		}
+
+		actor := queueActor(c, db)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if target.ID != queue.TopicID {
+				queueNo, err := allocateQueueNo(tx, target, queue.ServiceDate)
+				if err != nil {
+					return err
+				}
+				transfer.ToNo = queueNo
+			}
+
+			updates := map[string]interface{}{
+				"topic_id":            target.ID,
+				"no":                  transfer.ToNo,
+				"counter_id":          nil,
+				"assigned_counter_id": body.CounterID,
+			}
+			if !body.KeepPosition {
+				updates["order_at"] = time.Now()
+			}
+			if queue.Status != helpers.WAITING {
+				if err := TransitionQueue(tx, &queue, helpers.WAITING, updates, actor); err != nil {
+					return err
+				}
+			} else {
+				result := tx.Model(&models.Queue{}).Where("id = ? AND status = ?", queue.ID, helpers.WAITING).Updates(updates)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return fmt.Errorf("%w: queue %d is no longer %s", ErrIllegalTransition, queue.ID, helpers.WAITING)
+				}
+			}
+			if err := tx.Create(&transfer).Error; err != nil {
+				return err
+			}
+			if err := tx.Preload("Topic").First(&queue, queue.ID).Error; err != nil {
+				return err
+			}
+			return RecordQueueEvent(tx, queue, helpers.QUEUE_EVENT_TRANSFER, &before.Status, actor, transfer)
+		})
+		if err != nil {
+			transitionErrorResponse(c, err, "Failed to transfer queue")
+			return
+		}
+
+		if err := db.Preload("Topic").First(&queue, queue.ID).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue details")
+			return
+		}
+		waiting, err := FindWaitingQueue(db, queue.Topic, queue.ID)
+		if err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to count waiting queues")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "transferQueue",
+			"data": map[string]interface{}{
+				"queue":    queue,
+				"transfer": transfer,
+				"waiting":  waiting,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "transfer", "queue", queue.ID, before, queue)
+		helpers.FormatSuccessResponse(c, map[string]interface{}{
+			"queue":    queue,
+			"transfer": transfer,
+			"waiting":  waiting,
+		})
+	}
+}
+
+func GetQueueTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var queue models.Queue
+		if err := db.First(&queue, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				helpers.FormatErrorResponse(c, http.StatusNotFound, "Queue not found")
+				return
+			}
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve queue")
+			return
+		}
+
+		var transfers []models.QueueTransfer
+		if err := db.Where("queue_id = ?", queue.ID).Order("created_at ASC, id ASC").Find(&transfers).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch queue transfers")
+			return
+		}
+		helpers.FormatSuccessResponse(c, transfers)
+	}
+}
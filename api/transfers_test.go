@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"src/helpers"
+	"src/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransferKeepsTargetSequenceAhead(t *testing.T) {
+	db := openTestDB(t)
+	hub := startTestHub()
+	prefix := "X"
+	source := models.Topic{TopicTH: "S", TopicEN: "S", Code: "S", NoPrefix: &prefix, NoWidth: 3, NoStart: 1, NoStep: 1, NoReset: helpers.RESET_DAILY}
+	target := models.Topic{TopicTH: "T", TopicEN: "T", Code: "T", NoPrefix: &prefix, NoWidth: 3, NoStart: 1, NoStep: 1, NoReset: helpers.RESET_DAILY}
+	db.Create(&source)
+	db.Create(&target)
+	serviceDate, _ := helpers.GetStartAndEndOfDay()
+
+	no, err := allocateQueueNo(db, source, serviceDate)
+	if err != nil {
+		t.Fatalf("failed to allocate number: %v", err)
+	}
+	queue := seedQueue(t, db, models.Queue{No: no, TopicID: source.ID, ServiceDate: serviceDate})
+
+	res := performRequest(TransferQueue(db, hub), http.MethodPost, "/queue/transfer", fmt.Sprintf(`{"topicId":%d}`, target.ID),
+		adminClaims(), gin.Params{{Key: "id", Value: fmt.Sprint(queue.ID)}})
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+
+	for i := 0; i < 3; i++ {
+		next, err := allocateQueueNo(db, target, serviceDate)
+		if err != nil {
+			t.Fatalf("failed to allocate number: %v", err)
+		}
+		if err := db.Create(&models.Queue{No: next, Firstname: "N", Lastname: next, TopicID: target.ID, ServiceDate: serviceDate}).Error; err != nil {
+			t.Fatalf("allocating %s after a transfer collided: %v", next, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"src/helpers"
 	"src/models"
@@ -23,7 +25,7 @@ func GetUserInfo(db *gorm.DB) gin.HandlerFunc {
 
 		var user models.User
 		err := db.Preload("Counter", func(db *gorm.DB) *gorm.DB {
-			return db.Select("ID", "Counter", "TimeClosed", "Status")
+			return db.Select("ID", "Counter", "TimeOpened", "TimeClosed", "Status")
 		}).Where("email = ?", email).First(&user).Error
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -37,3 +39,212 @@ func GetUserInfo(db *gorm.DB) gin.HandlerFunc {
 		helpers.FormatSuccessResponse(c, user)
 	}
 }
+
+func validStaffRole(role string) bool {
+	switch role {
+	case helpers.ADMIN, helpers.COUNTER_STAFF, helpers.VIEWER:
+		return true
+	}
+	return false
+}
+
+func isSelf(c *gin.Context, user models.User) bool {
+	userClaims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return false
+	}
+	email, _ := userClaims["email"].(string)
+	return email == user.Email
+}
+
+func GetUsers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Preload("Counter", func(db *gorm.DB) *gorm.DB {
+			return db.Select("ID", "Counter", "TimeOpened", "TimeClosed", "Status")
+		})
+		if role := c.Query("role"); role != "" {
+			query = query.Where("role = ?", role)
+		}
+		if disabled := c.Query("disabled"); disabled != "" {
+			query = query.Where("disabled = ?", disabled == "true")
+		}
+
+		var users []models.User
+		if err := query.Order("id ASC").Find(&users).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+			return
+		}
+		helpers.FormatSuccessResponse(c, users)
+	}
+}
+
+func CreateUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || body.Email == "" {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if body.Role == "" {
+			body.Role = helpers.COUNTER_STAFF
+		}
+		if !validStaffRole(body.Role) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid role")
+			return
+		}
+
+		var existingUser models.User
+		if err := db.Where("email = ?", body.Email).First(&existingUser).Error; err == nil {
+			helpers.FormatErrorResponse(c, http.StatusConflict, fmt.Sprintf("The email '%v' already exists.", body.Email))
+			return
+		} else if err != gorm.ErrRecordNotFound {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check for existing user")
+			return
+		}
+
+		user := models.User{
+			Email: body.Email,
+			Role:  body.Role,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to create user")
+			return
+		}
+		recordAudit(db, c, "create", "user", user.ID, nil, user)
+		helpers.FormatSuccessResponse(c, user)
+	}
+}
+
+func UpdateUserRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var body struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || !validStaffRole(body.Role) {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid role")
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if isSelf(c, user) {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "You cannot change your own role")
+			return
+		}
+		before := user
+
+		tx := db.Begin()
+		if err := tx.Model(&user).Update("role", body.Role).Error; err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update user role")
+			return
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions")
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to commit transaction")
+			return
+		}
+		recordAudit(db, c, "updateRole", "user", user.ID, before, user)
+		helpers.FormatSuccessResponse(c, user)
+	}
+}
+
+func UpdateUserStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var body struct {
+			Disabled bool `json:"disabled"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if isSelf(c, user) {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, "You cannot disable your own account")
+			return
+		}
+		before := user
+
+		tx := db.Begin()
+		if err := tx.Model(&user).Update("disabled", body.Disabled).Error; err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to update user status")
+			return
+		}
+		if body.Disabled {
+			if err := revokeUserSessions(tx, user.ID); err != nil {
+				tx.Rollback()
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions")
+				return
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to commit transaction")
+			return
+		}
+		recordAudit(db, c, "updateStatus", "user", user.ID, before, user)
+		helpers.FormatSuccessResponse(c, user)
+	}
+}
+
+func UnassignUserCounter(db *gorm.DB, hub *Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if user.CounterID == nil {
+			helpers.FormatErrorResponse(c, http.StatusBadRequest, "User is not assigned to a counter")
+			return
+		}
+		counterID := *user.CounterID
+		before := user
+
+		tx := db.Begin()
+		if err := tx.Model(&user).Update("counter_id", nil).Error; err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to detach user from counter")
+			return
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			tx.Rollback()
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions")
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to commit transaction")
+			return
+		}
+
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "unassignCounter",
+			"data": map[string]interface{}{
+				"userId":    user.ID,
+				"counterId": counterID,
+			},
+		})
+		hub.broadcast <- message
+
+		recordAudit(db, c, "unassignCounter", "user", user.ID, before, user)
+		helpers.FormatSuccessResponse(c, user)
+	}
+}
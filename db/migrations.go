@@ -2,24 +2,57 @@ package db
 
 import (
 	"log"
+	"os"
+	"src/api"
+	"src/helpers"
 	"src/models"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+var topicColumnBackfills = []string{
+	"no_start bigint NOT NULL DEFAULT 1",
+	"booking_lead_minutes bigint NOT NULL DEFAULT 60",
+	"cancel_cutoff_minutes bigint NOT NULL DEFAULT 60",
+	"check_in_grace_minutes bigint NOT NULL DEFAULT 15",
+	"requeue_offset bigint NOT NULL DEFAULT 3",
+	"cutoff_at_closing boolean NOT NULL DEFAULT true",
+}
+
 func CreateTables(db *gorm.DB) {
 	db.Exec("SET TIME ZONE 'Asia/Bangkok'")
 
+	backfillOrderAt := !db.Migrator().HasColumn(&models.Queue{}, "order_at")
+	if db.Migrator().HasTable(&models.Topic{}) {
+		for _, column := range topicColumnBackfills {
+			if err := db.Exec("ALTER TABLE topics ADD COLUMN IF NOT EXISTS " + column).Error; err != nil {
+				log.Printf("Failed to add topic column %s: %v", column, err)
+			}
+		}
+	}
+
 	err := db.AutoMigrate(
 		&models.Config{},
 		&models.Subscription{},
 		&models.Counter{},
 		&models.User{},
+		&models.Session{},
 		&models.Topic{},
 		&models.CounterTopic{},
 		&models.Queue{},
+		&models.QueueMember{},
+		&models.Attachment{},
+		&models.QueueSequence{},
+		&models.QueueTransfer{},
+		&models.QueueEvent{},
+		&models.PriorityGrant{},
+		&models.Appointment{},
 		&models.Feedback{},
 		&models.NotiSchedule{},
+		&models.AuditLog{},
+		&models.RateLimitBucket{},
+		&models.RateLimitBlock{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to auto-migrate models: %v", err)
@@ -27,9 +60,100 @@ func CreateTables(db *gorm.DB) {
 		log.Println("Successfully migrated tables")
 	}
 
+	if backfillOrderAt {
+		if err := db.Exec("UPDATE queues SET order_at = created_at").Error; err != nil {
+			log.Printf("Failed to backfill queue order: %v", err)
+		}
+	}
+	MigrateQueueStates(db)
+	MigrateQueueNumbering(db)
+	SeedAdmins(db)
+	SeedMockStaff(db)
 	// ResetSequences(db)
 }
 
+func MigrateQueueStates(db *gorm.DB) {
+	legacy := map[helpers.STATUS]helpers.STATUS{
+		helpers.IN_PROGRESS: helpers.SERVING,
+		helpers.CALLED:      helpers.DONE,
+	}
+	for from, to := range legacy {
+		if err := db.Model(&models.Queue{}).Where("status = ?", from).Update("status", to).Error; err != nil {
+			log.Printf("Failed to migrate %s queues to %s: %v", from, to, err)
+		}
+	}
+}
+
+func MigrateQueueNumbering(db *gorm.DB) {
+	err := db.Exec(`
+		UPDATE queues SET service_date = (created_at AT TIME ZONE 'Asia/Bangkok')::date
+		WHERE service_date IS NULL
+	`).Error
+	if err != nil {
+		log.Printf("Failed to backfill queue service dates: %v", err)
+		return
+	}
+
+	err = db.Exec(`
+		INSERT INTO queue_sequences (topic_id, period_start, last_no)
+		SELECT topic_id, service_date, GREATEST(COUNT(*), COALESCE(MAX(NULLIF(regexp_replace(no, '\D', '', 'g'), '')::int), 0))
+		FROM queues
+		GROUP BY topic_id, service_date
+		ON CONFLICT DO NOTHING
+	`).Error
+	if err != nil {
+		log.Printf("Failed to seed queue sequences: %v", err)
+		return
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_queues_topic_date_no ON queues (topic_id, service_date, no)").Error
+	if err != nil {
+		log.Printf("Failed to create unique queue number index: %v", err)
+	}
+}
+
+func SeedAdmins(db *gorm.DB) {
+	var adminCount int64
+	if err := db.Model(&models.User{}).Where("role = ?", helpers.ADMIN).Count(&adminCount).Error; err != nil {
+		log.Printf("Failed to count admins: %v", err)
+		return
+	}
+	if adminCount > 0 {
+		return
+	}
+
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		user := models.User{Email: email, Role: helpers.ADMIN}
+		err := db.Where("email = ?", email).
+			Assign(models.User{Role: helpers.ADMIN}).
+			FirstOrCreate(&user).Error
+		if err != nil {
+			log.Printf("Failed to seed admin %s: %v", email, err)
+			continue
+		}
+		log.Printf("Seeded admin %s", email)
+	}
+}
+
+func SeedMockStaff(db *gorm.DB) {
+	if strings.ToLower(os.Getenv("IDENTITY_PROVIDER")) != "mock" {
+		return
+	}
+	user := models.User{Email: api.MockStaffEmail, Role: api.MockStaffRole}
+	err := db.Where("email = ?", api.MockStaffEmail).
+		Assign(models.User{Role: api.MockStaffRole}).
+		FirstOrCreate(&user).Error
+	if err != nil {
+		log.Printf("Failed to seed mock staff %s: %v", api.MockStaffEmail, err)
+		return
+	}
+	log.Printf("Seeded mock staff %s as %s", api.MockStaffEmail, api.MockStaffRole)
+}
+
 func ResetSequences(db *gorm.DB) {
 	resetSequenceQuery := `
 		DO $$
@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"src/api"
 	"src/helpers"
 	"src/models"
+	"src/storage"
 	"time"
 
 	"gorm.io/gorm"
@@ -68,18 +70,17 @@ func UpdateCounterStatus(db *gorm.DB, hub *api.Hub) error {
 
 		var affectedQueue []models.Queue
 		err := tx.Model(&models.Queue{}).
-			Where("counter_id IN (?) AND status = ?", updatedCounterIDs, helpers.IN_PROGRESS).
+			Where("counter_id IN (?) AND status IN ?", updatedCounterIDs, []helpers.STATUS{helpers.CALLING, helpers.SERVING}).
 			Find(&affectedQueue).Error
 		if err != nil && err != gorm.ErrRecordNotFound {
 			tx.Rollback()
 			return fmt.Errorf("failed to update queue status: %v", err)
 		}
-		result := tx.Model(&models.Queue{}).
-			Where("id IN (?)", getQueueIDs(affectedQueue)).
-			Updates(map[string]interface{}{"status": helpers.CALLED})
-		if result.Error != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update queue status: %v", result.Error)
+		for i := range affectedQueue {
+			if err := api.FinishQueue(tx, &affectedQueue[i], api.SystemActor); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to update queue status: %v", err)
+			}
 		}
 		for _, queue := range affectedQueue {
 			message := map[string]interface{}{
@@ -107,6 +108,7 @@ func UpdateCounterStatus(db *gorm.DB, hub *api.Hub) error {
 				if err != nil {
 					log.Printf("Error sending notification for queue %d: %v", queue.ID, err)
 				}
+				api.NotifyQueueMembers(db, hub, string(messageJSON), queue)
 			}(queue)
 		}
 	}
@@ -119,30 +121,124 @@ func UpdateCounterStatus(db *gorm.DB, hub *api.Hub) error {
 	return nil
 }
 
-func getQueueIDs(queues []models.Queue) []int {
-	ids := make([]int, len(queues))
-	for i, q := range queues {
-		ids[i] = q.ID
+func StartAppointmentExpiry(db *gorm.DB, interval time.Duration, hub *api.Hub) {
+	go func() {
+		for {
+			err := ExpireAppointments(db, hub)
+			if err != nil {
+				log.Printf("Error expiring appointments: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func ExpireAppointments(db *gorm.DB, hub *api.Hub) error {
+	var expiredIDs []int
+	err := db.Raw(`
+		UPDATE appointments a SET status = ?, updated_at = now()
+		FROM topics t
+		WHERE a.topic_id = t.id AND a.status = ?
+		AND a.slot_start + t.check_in_grace_minutes * INTERVAL '1 minute' < now()
+		RETURNING a.id
+	`, helpers.APPOINTMENT_EXPIRED, helpers.APPOINTMENT_BOOKED).Scan(&expiredIDs).Error
+	if err != nil {
+		return fmt.Errorf("failed to expire appointments: %v", err)
+	}
+
+	if len(expiredIDs) > 0 {
+		message, _ := json.Marshal(map[string]interface{}{
+			"event": "expireAppointment",
+			"data":  expiredIDs,
+		})
+		hub.Broadcast(message)
+		log.Printf("Successfully expired %d appointments", len(expiredIDs))
 	}
-	return ids
+	return nil
 }
 
-func StartQueueCleanup(db *gorm.DB, interval time.Duration) {
+func StartNoShowMonitor(db *gorm.DB, interval time.Duration, hub *api.Hub) {
 	go func() {
 		for {
-			err := DeleteOldQueueEntries(db)
+			err := api.ResolveNoShows(db, hub)
+			if err != nil {
+				log.Printf("Error resolving no-shows: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func StartHoldExpiry(db *gorm.DB, interval time.Duration, hub *api.Hub) {
+	go func() {
+		for {
+			err := api.ExpireHolds(db, hub)
+			if err != nil {
+				log.Printf("Error expiring held queues: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func StartEtaMonitor(db *gorm.DB, interval time.Duration, hub *api.Hub) {
+	go func() {
+		for {
+			err := api.BroadcastEtaUpdates(db, hub)
+			if err != nil {
+				log.Printf("Error broadcasting wait estimates: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func StartSessionRevocationMonitor(db *gorm.DB, interval time.Duration, hub *api.Hub) {
+	go func() {
+		since := time.Now()
+		for {
+			checkedAt, err := api.DisconnectRevokedSessions(db, hub, since)
+			if err != nil {
+				log.Printf("Error disconnecting revoked sessions: %v", err)
+			} else {
+				since = checkedAt
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func StartQueueCleanup(db *gorm.DB, interval time.Duration, store storage.Storage) {
+	go func() {
+		for {
+			err := DeleteOldQueueEntries(db, store)
 			if err != nil {
 				log.Printf("Error deleting old queue entries: %v", err)
 			}
+			err = DeleteExpiredSessions(db)
+			if err != nil {
+				log.Printf("Error deleting expired sessions: %v", err)
+			}
 			time.Sleep(interval)
 		}
 	}()
 }
 
-func DeleteOldQueueEntries(db *gorm.DB) error {
+func DeleteOldQueueEntries(db *gorm.DB, store storage.Storage) error {
 	startOfDay, _ := helpers.GetStartAndEndOfDay()
 	thresholdDate := startOfDay.AddDate(0, 0, -30)
 
+	var attachments []models.Attachment
+	if err := db.Where("queue_id IN (?)", db.Model(&models.Queue{}).Select("id").Where("created_at < ?", thresholdDate)).
+		Find(&attachments).Error; err != nil {
+		return fmt.Errorf("failed to fetch old attachments: %v", err)
+	}
+	for _, attachment := range attachments {
+		if err := store.Delete(context.Background(), attachment.StorageKey); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", attachment.StorageKey, err)
+		}
+	}
+
 	result := db.Where("created_at < ?", thresholdDate).Delete(&models.Queue{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete old queue entries: %v", result.Error)
@@ -151,3 +247,13 @@ func DeleteOldQueueEntries(db *gorm.DB) error {
 	log.Printf("Successfully deleted %d old queue entries", result.RowsAffected)
 	return nil
 }
+
+func DeleteExpiredSessions(db *gorm.DB) error {
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete expired sessions: %v", result.Error)
+	}
+
+	log.Printf("Successfully deleted %d expired sessions", result.RowsAffected)
+	return nil
+}
@@ -3,12 +3,166 @@ package helpers
 type STATUS string
 
 const (
-	WAITING     STATUS = "WAITING"
+	WAITING   STATUS = "WAITING"
+	CALLING   STATUS = "CALLING"
+	SERVING   STATUS = "SERVING"
+	DONE      STATUS = "DONE"
+	CANCELLED STATUS = "CANCELLED"
+	NO_SHOW   STATUS = "NO_SHOW"
+	SKIPPED   STATUS = "SKIPPED"
+	HOLD      STATUS = "HOLD"
+	EXPIRED   STATUS = "EXPIRED"
+
 	IN_PROGRESS STATUS = "IN_PROGRESS"
 	CALLED      STATUS = "CALLED"
 )
 
+var ACTIVE_STATUSES = []STATUS{WAITING, CALLING, SERVING, SKIPPED, HOLD}
+
+func (s STATUS) Legacy() STATUS {
+	switch s {
+	case SKIPPED:
+		return WAITING
+	case CALLING, SERVING, HOLD:
+		return IN_PROGRESS
+	case DONE, CANCELLED, NO_SHOW, EXPIRED:
+		return CALLED
+	}
+	return s
+}
+
+func ParseStatus(value string) STATUS {
+	switch STATUS(value) {
+	case IN_PROGRESS:
+		return SERVING
+	case CALLED:
+		return DONE
+	}
+	return STATUS(value)
+}
+
+type QUEUE_EVENT string
+
+const (
+	QUEUE_EVENT_CREATED    QUEUE_EVENT = "CREATED"
+	QUEUE_EVENT_TRANSITION QUEUE_EVENT = "TRANSITION"
+	QUEUE_EVENT_TRANSFER   QUEUE_EVENT = "TRANSFER"
+	QUEUE_EVENT_RECALL     QUEUE_EVENT = "RECALL"
+	QUEUE_EVENT_CONFIRM    QUEUE_EVENT = "CONFIRM"
+	QUEUE_EVENT_DEFER      QUEUE_EVENT = "DEFER"
+	QUEUE_EVENT_PRIORITY   QUEUE_EVENT = "PRIORITY"
+)
+
+type NO_SHOW_ACTION string
+
+const (
+	NO_SHOW_ACTION_NONE    NO_SHOW_ACTION = "NONE"
+	NO_SHOW_ACTION_NO_SHOW NO_SHOW_ACTION = "NO_SHOW"
+	NO_SHOW_ACTION_REQUEUE NO_SHOW_ACTION = "REQUEUE"
+)
+
+type ROUTING_STRATEGY string
+
+const (
+	ROUTING_FIFO           ROUTING_STRATEGY = "FIFO"
+	ROUTING_ROUND_ROBIN    ROUTING_STRATEGY = "ROUND_ROBIN"
+	ROUTING_WEIGHTED       ROUTING_STRATEGY = "WEIGHTED"
+	ROUTING_PRIORITY_FIRST ROUTING_STRATEGY = "PRIORITY_FIRST"
+)
+
+type APPOINTMENT_STATUS string
+
+const (
+	APPOINTMENT_BOOKED     APPOINTMENT_STATUS = "BOOKED"
+	APPOINTMENT_CHECKED_IN APPOINTMENT_STATUS = "CHECKED_IN"
+	APPOINTMENT_CANCELLED  APPOINTMENT_STATUS = "CANCELLED"
+	APPOINTMENT_EXPIRED    APPOINTMENT_STATUS = "EXPIRED"
+)
+
+type PRIORITY string
+
+const (
+	PRIORITY_NORMAL      PRIORITY = "NORMAL"
+	PRIORITY_APPOINTMENT PRIORITY = "APPOINTMENT"
+	PRIORITY_VIP         PRIORITY = "VIP"
+	PRIORITY_PREGNANT    PRIORITY = "PREGNANT"
+	PRIORITY_DISABILITY  PRIORITY = "DISABILITY"
+)
+
+var PRIORITY_RANK = map[PRIORITY]int{
+	PRIORITY_NORMAL:      0,
+	PRIORITY_APPOINTMENT: 1,
+	PRIORITY_VIP:         2,
+	PRIORITY_PREGNANT:    3,
+	PRIORITY_DISABILITY:  4,
+}
+
+type RESET_PERIOD string
+
+const (
+	RESET_DAILY  RESET_PERIOD = "DAILY"
+	RESET_WEEKLY RESET_PERIOD = "WEEKLY"
+	RESET_TERM   RESET_PERIOD = "TERM"
+	RESET_NEVER  RESET_PERIOD = "NEVER"
+)
+
+type GUEST_ACCESS string
+
+const (
+	GUEST_ACCESS_DEFAULT  GUEST_ACCESS = "DEFAULT"
+	GUEST_ACCESS_OPEN     GUEST_ACCESS = "OPEN"
+	GUEST_ACCESS_CMU_ONLY GUEST_ACCESS = "CMU_ONLY"
+)
+
+type AVAILABILITY string
+
+const (
+	AVAILABILITY_OPEN   AVAILABILITY = "open"
+	AVAILABILITY_FULL   AVAILABILITY = "full"
+	AVAILABILITY_CLOSED AVAILABILITY = "closed"
+)
+
+type ERROR_CODE string
+
+const (
+	GUEST_RESERVATION_DISABLED ERROR_CODE = "GUEST_RESERVATION_DISABLED"
+	TICKET_LIMIT_REACHED       ERROR_CODE = "TICKET_LIMIT_REACHED"
+	DUPLICATE_TOPIC_TICKET     ERROR_CODE = "DUPLICATE_TOPIC_TICKET"
+	NO_SHOW_COOLDOWN           ERROR_CODE = "NO_SHOW_COOLDOWN"
+	TOPIC_NOT_OPEN_YET         ERROR_CODE = "TOPIC_NOT_OPEN_YET"
+	TOPIC_ISSUANCE_CLOSED      ERROR_CODE = "TOPIC_ISSUANCE_CLOSED"
+	TOPIC_DAILY_CAP_REACHED    ERROR_CODE = "TOPIC_DAILY_CAP_REACHED"
+	TOPIC_PAST_CLOSING         ERROR_CODE = "TOPIC_PAST_CLOSING"
+)
+
+const (
+	ADMIN         = "Admin"
+	COUNTER_STAFF = "CounterStaff"
+	VIEWER        = "Viewer"
+	STUDENT       = "Student"
+	GUEST         = "Guest"
+)
+
+type PERMISSION string
+
 const (
-	ADMIN   = "Admin"
-	STUDENT = "Student"
+	MANAGE_CONFIG     PERMISSION = "config:manage"
+	MANAGE_USERS      PERMISSION = "user:manage"
+	VIEW_AUDIT_LOGS   PERMISSION = "audit:view"
+	VIEW_RATE_LIMITS  PERMISSION = "ratelimit:view"
+	MANAGE_PRIORITY   PERMISSION = "priority:manage"
+	MANAGE_COUNTERS   PERMISSION = "counter:manage"
+	OPERATE_COUNTER   PERMISSION = "counter:operate"
+	MANAGE_TOPICS     PERMISSION = "topic:manage"
+	MANAGE_SCHEDULES  PERMISSION = "schedule:manage"
+	VIEW_QUEUES       PERMISSION = "queue:view"
+	SERVE_QUEUES      PERMISSION = "queue:serve"
+	VIEW_OWN_QUEUE    PERMISSION = "queue:own"
+	SEND_NOTIFICATION PERMISSION = "notification:send"
+	SUBSCRIBE         PERMISSION = "notification:subscribe"
+	VIEW_PROFILE      PERMISSION = "profile:view"
+	VIEW_FEEDBACK     PERMISSION = "feedback:view"
+	GIVE_FEEDBACK     PERMISSION = "feedback:give"
+	BOOK_APPOINTMENT  PERMISSION = "appointment:book"
+	VIEW_ATTACHMENTS  PERMISSION = "attachment:view"
 )
@@ -36,9 +36,13 @@ func FormatErrorResponse(c *gin.Context, statusCode int, data interface{}) {
 }
 
 func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithoutClaimsValidation(),
+	)
+	token, err := parser.Parse(tokenString, lookupVerificationKey)
 	if err != nil {
-		return nil, errors.New("Invalid token")
+		return nil, tokenError(err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -46,6 +50,10 @@ func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 		return nil, errors.New("Invalid claims in token")
 	}
 
+	if err := validateTimeClaims(claims); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
 
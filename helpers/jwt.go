@@ -0,0 +1,226 @@
+package helpers
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	DEFAULT_ACCESS_TOKEN_TTL  = 15 * time.Minute
+	DEFAULT_REFRESH_TOKEN_TTL = 7 * 24 * time.Hour
+)
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+type keySet struct {
+	current *signingKey
+	keys    map[string]*signingKey
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var (
+	jwtKeys     *keySet
+	jwtKeysErr  error
+	jwtKeysOnce sync.Once
+)
+
+func loadKeySet() (*keySet, error) {
+	jwtKeysOnce.Do(func() {
+		jwtKeys, jwtKeysErr = buildKeySet()
+	})
+	return jwtKeys, jwtKeysErr
+}
+
+func buildKeySet() (*keySet, error) {
+	set := &keySet{keys: map[string]*signingKey{}}
+
+	kid := os.Getenv("JWT_KEY_ID")
+	if kid == "" {
+		kid = "default"
+	}
+
+	switch strings.ToUpper(os.Getenv("JWT_SIGNING_ALG")) {
+	case "", "HS256":
+		secret := os.Getenv("JWT_SECRET_KEY")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET_KEY is not set")
+		}
+		set.current = &signingKey{kid: kid, method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+	case "RS256":
+		pem, err := os.ReadFile(os.Getenv("JWT_RSA_PRIVATE_KEY_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_RSA_PRIVATE_KEY_FILE: %v", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %v", err)
+		}
+		set.current = &signingKey{kid: kid, method: jwt.SigningMethodRS256, sign: privateKey, verify: &privateKey.PublicKey}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q", os.Getenv("JWT_SIGNING_ALG"))
+	}
+	set.keys[kid] = set.current
+
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_JWKS_FILE: %v", err)
+		}
+		var jwks struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := json.Unmarshal(raw, &jwks); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT_JWKS_FILE: %v", err)
+		}
+		for _, k := range jwks.Keys {
+			key, err := parseJWK(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := set.keys[key.kid]; !exists {
+				set.keys[key.kid] = key
+			}
+		}
+	}
+
+	return set, nil
+}
+
+func parseJWK(k jwk) (*signingKey, error) {
+	if k.Kid == "" {
+		return nil, errors.New("JWKS key is missing kid")
+	}
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid oct key %q: %v", k.Kid, err)
+		}
+		return &signingKey{kid: k.Kid, method: jwt.SigningMethodHS256, verify: secret}, nil
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus for key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent for key %q: %v", k.Kid, err)
+		}
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &signingKey{kid: k.Kid, method: jwt.SigningMethodRS256, verify: publicKey}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q for key %q", k.Kty, k.Kid)
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv(name)); err == nil && ttl > 0 {
+		return ttl
+	}
+	return fallback
+}
+
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("JWT_EXPIRES_IN", DEFAULT_ACCESS_TOKEN_TTL)
+}
+
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("JWT_REFRESH_EXPIRES_IN", DEFAULT_REFRESH_TOKEN_TTL)
+}
+
+func SignToken(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	keys, err := loadKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(keys.current.method, claims)
+	token.Header["kid"] = keys.current.kid
+	return token.SignedString(keys.current.sign)
+}
+
+func lookupVerificationKey(token *jwt.Token) (interface{}, error) {
+	keys, err := loadKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys.current
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = keys.keys[kid]; !ok {
+			return nil, fmt.Errorf("Unknown signing key %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+func tokenError(err error) error {
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) {
+		return errors.New("Invalid token")
+	}
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return errors.New("Malformed token")
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		if validationErr.Inner != nil {
+			return validationErr.Inner
+		}
+		return errors.New("Token could not be verified")
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return errors.New("Invalid token signature")
+	}
+	return errors.New("Invalid token")
+}
+
+func validateTimeClaims(claims jwt.MapClaims) error {
+	now := time.Now().Unix()
+	for _, name := range []string{"exp", "nbf", "iat"} {
+		if _, ok := claims[name]; !ok {
+			return fmt.Errorf("Token is missing the %s claim", name)
+		}
+	}
+	if !claims.VerifyExpiresAt(now, true) {
+		return errors.New("Token has expired")
+	}
+	if !claims.VerifyNotBefore(now, true) {
+		return errors.New("Token is not valid yet")
+	}
+	if !claims.VerifyIssuedAt(now, true) {
+		return errors.New("Token was issued in the future")
+	}
+	return nil
+}
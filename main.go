@@ -6,6 +6,8 @@ import (
 	"os"
 	"src/api"
 	"src/db"
+	"src/storage"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,15 +31,32 @@ func main() {
 		sqlDB.Close()
 	}()
 
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialise attachment storage: %v", err)
+	}
+
 	hub := api.NewHub()
 	go hub.Run()
 
 	db.StartCounterStatusUpdater(dbConn, time.Minute, hub)
-	db.StartQueueCleanup(dbConn, 24*time.Hour)
+	db.StartAppointmentExpiry(dbConn, time.Minute, hub)
+	db.StartNoShowMonitor(dbConn, time.Minute, hub)
+	db.StartHoldExpiry(dbConn, time.Minute, hub)
+	db.StartEtaMonitor(dbConn, 30*time.Second, hub)
+	db.StartSessionRevocationMonitor(dbConn, 30*time.Second, hub)
+	db.StartQueueCleanup(dbConn, 24*time.Hour, store)
 
 	router := gin.Default()
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(func(c *gin.Context) {
-		if c.Request.ContentLength > 2*1024*1024 { // 2 MB
+		limit := int64(2 * 1024 * 1024) // 2 MB
+		if c.Request.Method == http.MethodPost && strings.HasSuffix(c.Request.URL.Path, "/attachments") {
+			limit = api.MaxAttachmentBytes() + 1024*1024
+		}
+		if c.Request.ContentLength > limit {
 			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
 				"error": "Request body too large",
 			})
@@ -57,11 +76,21 @@ func main() {
 	router.Use(gin.Recovery())
 
 	router.GET("/api", func(c *gin.Context) {
-		api.ServeWs(hub, c.Writer, c.Request)
+		api.ServeWs(hub, dbConn, c.Writer, c.Request)
 	})
 
 	apiV1 := router.Group("/api/v1")
-	api.RegisterRoutes(apiV1, dbConn, hub)
+	api.RegisterRoutes(apiV1, dbConn, hub, store)
 
 	log.Fatal(router.Run(":" + port))
 }
+
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -1,14 +1,43 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"src/helpers"
+	"src/models"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
-func AuthRequired() gin.HandlerFunc {
+func Authenticate(db *gorm.DB, token string) (jwt.MapClaims, error) {
+	claims, err := helpers.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return nil, errors.New("Token is not bound to a session")
+	}
+
+	var session models.Session
+	if err := db.Select("id", "revoked_at").Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("Session not found")
+		}
+		return nil, errors.New("Failed to verify session")
+	}
+	if session.RevokedAt != nil {
+		return nil, errors.New("Session has been revoked")
+	}
+
+	return claims, nil
+}
+
+func AuthRequired(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
@@ -23,7 +52,7 @@ func AuthRequired() gin.HandlerFunc {
 
 		token = strings.TrimPrefix(token, "Bearer ")
 
-		claims, err := helpers.VerifyToken(token)
+		claims, err := Authenticate(db, token)
 		if err != nil {
 			helpers.FormatErrorResponse(c, http.StatusUnauthorized, err.Error())
 			return
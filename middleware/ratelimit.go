@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"src/helpers"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RateLimitRule struct {
+	Scope string
+	Burst float64
+	Rate  float64
+}
+
+func ParseRateLimitRule(scope, value string, fallback RateLimitRule) RateLimitRule {
+	count, period, found := strings.Cut(value, "/")
+	if !found {
+		return fallback
+	}
+	burst, err := strconv.Atoi(count)
+	if err != nil || burst <= 0 {
+		return fallback
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return fallback
+	}
+	return RateLimitRule{Scope: scope, Burst: float64(burst), Rate: float64(burst) / duration.Seconds()}
+}
+
+func RateLimitRuleFromEnv(scope, name, fallback string) RateLimitRule {
+	defaultRule := ParseRateLimitRule(scope, fallback, RateLimitRule{Scope: scope, Burst: 1, Rate: 1})
+	return ParseRateLimitRule(scope, os.Getenv(name), defaultRule)
+}
+
+type RateLimitStore interface {
+	Take(key string, rule RateLimitRule) (bool, time.Duration, error)
+	RecordBlocked(scope string) error
+	Blocked() (map[string]BlockedCount, error)
+}
+
+type BlockedCount struct {
+	Blocked       int64
+	LastBlockedAt time.Time
+}
+
+func retryAfter(tokens float64, rule RateLimitRule) time.Duration {
+	return time.Duration(math.Ceil((1-tokens)/rule.Rate)) * time.Second
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	blocked   map[string]BlockedCount
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucket{}, blocked: map[string]BlockedCount{}, lastSweep: time.Now()}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rule RateLimitRule) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > 10*time.Minute {
+		for k, b := range s.buckets {
+			if now.Sub(b.updatedAt) > time.Hour {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rule.Burst, updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(rule.Burst, b.tokens+now.Sub(b.updatedAt).Seconds()*rule.Rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false, retryAfter(b.tokens, rule), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+func (s *MemoryRateLimitStore) RecordBlocked(scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := s.blocked[scope]
+	count.Blocked++
+	count.LastBlockedAt = time.Now()
+	s.blocked[scope] = count
+	return nil
+}
+
+func (s *MemoryRateLimitStore) Blocked() (map[string]BlockedCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := make(map[string]BlockedCount, len(s.blocked))
+	for scope, count := range s.blocked {
+		blocked[scope] = count
+	}
+	return blocked, nil
+}
+
+type PostgresRateLimitStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresRateLimitStore(db *gorm.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+func (s *PostgresRateLimitStore) Take(key string, rule RateLimitRule) (bool, time.Duration, error) {
+	var result struct {
+		Tokens  float64
+		Allowed bool
+	}
+	err := s.db.Raw(`
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES (@key, @burst - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM (now() - b.updated_at)) * @rate) >= 1
+				THEN LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM (now() - b.updated_at)) * @rate) - 1
+				ELSE LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM (now() - b.updated_at)) * @rate)
+			END,
+			allowed = LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM (now() - b.updated_at)) * @rate) >= 1,
+			updated_at = now()
+		RETURNING b.tokens, b.allowed
+	`, map[string]interface{}{"key": key, "burst": rule.Burst, "rate": rule.Rate}).Scan(&result).Error
+	if err != nil {
+		return false, 0, err
+	}
+	if !result.Allowed {
+		return false, retryAfter(result.Tokens, rule), nil
+	}
+	return true, 0, nil
+}
+
+func (s *PostgresRateLimitStore) RecordBlocked(scope string) error {
+	return s.db.Exec(`
+		INSERT INTO rate_limit_blocks AS b (scope, blocked, last_blocked_at)
+		VALUES (?, 1, now())
+		ON CONFLICT (scope) DO UPDATE SET blocked = b.blocked + 1, last_blocked_at = now()
+	`, scope).Error
+}
+
+func (s *PostgresRateLimitStore) Blocked() (map[string]BlockedCount, error) {
+	var rows []struct {
+		Scope         string
+		Blocked       int64
+		LastBlockedAt time.Time
+	}
+	if err := s.db.Table("rate_limit_blocks").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]BlockedCount, len(rows))
+	for _, row := range rows {
+		blocked[row.Scope] = BlockedCount{Blocked: row.Blocked, LastBlockedAt: row.LastBlockedAt}
+	}
+	return blocked, nil
+}
+
+type RateLimitKey struct {
+	Scope string
+	Value string
+}
+
+type RateLimiter struct {
+	store RateLimitStore
+	rules map[string]RateLimitRule
+}
+
+func NewRateLimiter(store RateLimitStore, rules ...RateLimitRule) *RateLimiter {
+	limiter := &RateLimiter{
+		store: store,
+		rules: map[string]RateLimitRule{},
+	}
+	for _, rule := range rules {
+		limiter.rules[rule.Scope] = rule
+	}
+	return limiter
+}
+
+func (l *RateLimiter) Stats() (map[string]interface{}, error) {
+	blocked, err := l.store.Blocked()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]interface{}{}
+	for scope, rule := range l.rules {
+		var lastBlockedAt *time.Time
+		count, ok := blocked[scope]
+		if ok {
+			lastBlockedAt = &count.LastBlockedAt
+		}
+		stats[scope] = map[string]interface{}{
+			"burst":         rule.Burst,
+			"perMinute":     rule.Rate * 60,
+			"blocked":       count.Blocked,
+			"lastBlockedAt": lastBlockedAt,
+		}
+	}
+	return stats, nil
+}
+
+func (l *RateLimiter) Limit(keys func(c *gin.Context) []RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, key := range keys(c) {
+			rule, ok := l.rules[key.Scope]
+			if !ok || key.Value == "" {
+				continue
+			}
+			allowed, wait, err := l.store.Take(key.Scope+":"+strings.ToLower(key.Value), rule)
+			if err != nil {
+				helpers.FormatErrorResponse(c, http.StatusInternalServerError, "Failed to check rate limit")
+				return
+			}
+			if !allowed {
+				if err := l.store.RecordBlocked(key.Scope); err != nil {
+					log.Printf("Error recording blocked request for %s: %v", key.Scope, err)
+				}
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())))
+				helpers.FormatErrorResponse(c, http.StatusTooManyRequests, fmt.Sprintf("Too many requests, retry after %d seconds", int(wait.Seconds())))
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"src/helpers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var RolePermissions = map[string][]helpers.PERMISSION{
+	helpers.ADMIN: {
+		helpers.MANAGE_CONFIG,
+		helpers.MANAGE_USERS,
+		helpers.VIEW_AUDIT_LOGS,
+		helpers.VIEW_RATE_LIMITS,
+		helpers.MANAGE_PRIORITY,
+		helpers.MANAGE_COUNTERS,
+		helpers.OPERATE_COUNTER,
+		helpers.MANAGE_TOPICS,
+		helpers.MANAGE_SCHEDULES,
+		helpers.VIEW_QUEUES,
+		helpers.SERVE_QUEUES,
+		helpers.SEND_NOTIFICATION,
+		helpers.VIEW_PROFILE,
+		helpers.VIEW_FEEDBACK,
+		helpers.VIEW_ATTACHMENTS,
+	},
+	helpers.COUNTER_STAFF: {
+		helpers.OPERATE_COUNTER,
+		helpers.VIEW_QUEUES,
+		helpers.SERVE_QUEUES,
+		helpers.SEND_NOTIFICATION,
+		helpers.VIEW_PROFILE,
+		helpers.VIEW_FEEDBACK,
+		helpers.VIEW_ATTACHMENTS,
+	},
+	helpers.VIEWER: {
+		helpers.VIEW_QUEUES,
+		helpers.VIEW_PROFILE,
+	},
+	helpers.STUDENT: {
+		helpers.VIEW_OWN_QUEUE,
+		helpers.SUBSCRIBE,
+		helpers.GIVE_FEEDBACK,
+		helpers.BOOK_APPOINTMENT,
+		helpers.VIEW_ATTACHMENTS,
+	},
+	helpers.GUEST: {
+		helpers.VIEW_OWN_QUEUE,
+		helpers.SUBSCRIBE,
+		helpers.GIVE_FEEDBACK,
+		helpers.VIEW_ATTACHMENTS,
+	},
+}
+
+func HasPermission(role string, permission helpers.PERMISSION) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func RequirePermission(permission helpers.PERMISSION) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := helpers.ExtractClaims(c)
+		if !ok {
+			return
+		}
+		role, _ := claims["role"].(string)
+		if !HasPermission(role, permission) {
+			helpers.FormatErrorResponse(c, http.StatusForbidden, fmt.Sprintf("Role '%s' is not allowed to perform '%s'", role, permission))
+			return
+		}
+		c.Next()
+	}
+}
+
+func ClaimCounterID(claims jwt.MapClaims) (int, bool) {
+	counterID, ok := claims["counterId"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(counterID), true
+}
+
+func CanAccessCounter(c *gin.Context, counterID int) bool {
+	claims, ok := helpers.ExtractClaims(c)
+	if !ok {
+		return false
+	}
+	if claims["role"] == helpers.ADMIN {
+		return true
+	}
+	ownCounterID, ok := ClaimCounterID(claims)
+	if !ok || ownCounterID != counterID {
+		helpers.FormatErrorResponse(c, http.StatusForbidden, "You can only act on your own counter")
+		return false
+	}
+	return true
+}
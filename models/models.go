@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"src/helpers"
 	"time"
 
@@ -8,9 +9,15 @@ import (
 )
 
 type Config struct {
-	ID          int    `json:"id" gorm:"primaryKey"`
-	LoginNotCmu bool   `json:"loginNotCmu" gorm:"default:true;not null"`
-	Audio       string `json:"audio" gorm:"size:20;default:'th';not null"`
+	ID                    int                      `json:"id" gorm:"primaryKey"`
+	LoginNotCmu           bool                     `json:"loginNotCmu" gorm:"default:true;not null"`
+	Audio                 string                   `json:"audio" gorm:"size:20;default:'th';not null"`
+	TermStarts            pq.StringArray           `json:"termStarts" gorm:"type:date[];default:'{}'"`
+	PriorityAgingMinutes  int                      `json:"priorityAgingMinutes" gorm:"default:15;not null"`
+	RoutingStrategy       helpers.ROUTING_STRATEGY `json:"routingStrategy" gorm:"size:20;default:'PRIORITY_FIRST';not null"`
+	MaxActiveTickets      int                      `json:"maxActiveTickets" gorm:"default:1;not null"`
+	OneTicketPerTopic     bool                     `json:"oneTicketPerTopic" gorm:"default:true;not null"`
+	NoShowCooldownMinutes int                      `json:"noShowCooldownMinutes" gorm:"default:30;not null"`
 }
 
 type Subscription struct {
@@ -26,6 +33,7 @@ type Counter struct {
 	ID         int     `json:"id" gorm:"primaryKey;autoIncrement"`
 	Counter    string  `json:"counter" gorm:"unique;not null"`
 	Status     bool    `json:"status" gorm:"default:false;not null"`
+	TimeOpened string  `json:"timeOpened" gorm:"type:time(3);default:'08:30:00';not null"`
 	TimeClosed string  `json:"timeClosed" gorm:"type:time(3);default:'16:00:00';not null"`
 	User       *User   `json:"user" gorm:"foreignKey:CounterID;constraint:OnDelete:SET NULL"`
 	Topics     []Topic `json:"topics" gorm:"many2many:counter_topics;constraint:OnDelete:CASCADE"`
@@ -38,15 +46,54 @@ type User struct {
 	FirstNameEN *string `json:"firstNameEN" gorm:"size:100"`
 	LastNameEN  *string `json:"lastNameEN" gorm:"size:100"`
 	Email       string  `json:"email" gorm:"unique;size:100;not null"`
+	Role        string  `json:"role" gorm:"size:20;default:'CounterStaff';not null"`
+	Disabled    bool    `json:"disabled" gorm:"default:false;not null"`
 	CounterID   *int    `json:"counterId" gorm:"foreignKey:CounterID;constraint:OnDelete:SET NULL"`
 	Counter     Counter `json:"counter" gorm:"foreignKey:CounterID;constraint:OnDelete:SET NULL"`
 }
 
+type Session struct {
+	ID               string     `json:"id" gorm:"primaryKey;size:32"`
+	UserID           *int       `json:"userId" gorm:"index"`
+	Subject          string     `json:"subject" gorm:"size:255;not null"`
+	Claims           string     `json:"-" gorm:"type:jsonb;not null"`
+	RefreshTokenHash string     `json:"-" gorm:"size:64;not null"`
+	ExpiresAt        time.Time  `json:"expiresAt" gorm:"not null"`
+	RevokedAt        *time.Time `json:"revokedAt"`
+	LastUsedAt       time.Time  `json:"lastUsedAt" gorm:"default:current_timestamp"`
+	CreatedAt        time.Time  `json:"createdAt" gorm:"default:current_timestamp"`
+}
+
 type Topic struct {
-	ID      int    `json:"id" gorm:"primaryKey;autoIncrement"`
-	TopicTH string `json:"topicTH" gorm:"unique;not null"`
-	TopicEN string `json:"topicEN" gorm:"unique;not null"`
-	Code    string `json:"code" gorm:"unique;not null"`
+	ID          int                  `json:"id" gorm:"primaryKey;autoIncrement"`
+	TopicTH     string               `json:"topicTH" gorm:"unique;not null"`
+	TopicEN     string               `json:"topicEN" gorm:"unique;not null"`
+	Code        string               `json:"code" gorm:"unique;not null"`
+	GuestAccess helpers.GUEST_ACCESS `json:"guestAccess" gorm:"size:20;default:'DEFAULT';not null"`
+	NoPrefix    *string              `json:"noPrefix" gorm:"size:20"`
+	NoWidth     int                  `json:"noWidth" gorm:"default:3;not null"`
+	NoStart     int                  `json:"noStart" gorm:"not null"`
+	NoStep      int                  `json:"noStep" gorm:"default:1;not null"`
+	NoReset     helpers.RESET_PERIOD `json:"noReset" gorm:"size:20;default:'DAILY';not null"`
+
+	SlotMinutes         int `json:"slotMinutes" gorm:"default:0;not null"`
+	SlotCapacity        int `json:"slotCapacity" gorm:"default:1;not null"`
+	BookingLeadMinutes  int `json:"bookingLeadMinutes" gorm:"not null"`
+	BookingDays         int `json:"bookingDays" gorm:"default:14;not null"`
+	CancelCutoffMinutes int `json:"cancelCutoffMinutes" gorm:"not null"`
+	CheckInGraceMinutes int `json:"checkInGraceMinutes" gorm:"not null"`
+
+	NoShowAction       helpers.NO_SHOW_ACTION `json:"noShowAction" gorm:"size:20;default:'NONE';not null"`
+	NoShowMaxRecalls   int                    `json:"noShowMaxRecalls" gorm:"default:3;not null"`
+	NoShowGraceMinutes int                    `json:"noShowGraceMinutes" gorm:"default:5;not null"`
+	RequeueOffset      int                    `json:"requeueOffset" gorm:"not null"`
+
+	Weight int `json:"weight" gorm:"default:1;not null"`
+
+	IssueOpensAt    *string `json:"issueOpensAt" gorm:"type:time(3)"`
+	IssueClosesAt   *string `json:"issueClosesAt" gorm:"type:time(3)"`
+	DailyCap        int     `json:"dailyCap" gorm:"default:0;not null"`
+	CutoffAtClosing bool    `json:"cutoffAtClosing" gorm:"not null"`
 }
 
 type CounterTopic struct {
@@ -57,18 +104,128 @@ type CounterTopic struct {
 }
 
 type Queue struct {
-	ID        int            `json:"id" gorm:"primaryKey;autoIncrement"`
-	No        string         `json:"no" gorm:"not null"`
-	StudentID *string        `json:"studentId" gorm:"size:9"`
-	Firstname string         `json:"firstName" gorm:"not null"`
-	Lastname  string         `json:"lastName" gorm:"not null"`
-	TopicID   int            `json:"topicId" gorm:"foreignKey:TopicID;constraint:OnDelete:CASCADE"`
-	Topic     Topic          `json:"topic" gorm:"foreignKey:TopicID;constraint:OnDelete:CASCADE"`
-	Note      *string        `json:"note" gorm:"size:255"`
-	Status    helpers.STATUS `json:"status" gorm:"default:'WAITING';not null"`
-	CounterID *int           `json:"counterId" gorm:"foreignKey:CounterID;constraint:OnDelete:CASCADE"`
-	Feedback  bool           `json:"feedback" gorm:"default:false;not null"`
-	CreatedAt time.Time      `json:"createdAt" gorm:"default:current_timestamp"`
+	ID                int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	No                string           `json:"no" gorm:"not null"`
+	StudentID         *string          `json:"studentId" gorm:"size:9"`
+	Firstname         string           `json:"firstName" gorm:"not null"`
+	Lastname          string           `json:"lastName" gorm:"not null"`
+	TopicID           int              `json:"topicId" gorm:"foreignKey:TopicID;constraint:OnDelete:CASCADE"`
+	Topic             Topic            `json:"topic" gorm:"foreignKey:TopicID;constraint:OnDelete:CASCADE"`
+	Note              *string          `json:"note" gorm:"size:255"`
+	Status            helpers.STATUS   `json:"status" gorm:"default:'WAITING';not null"`
+	Priority          helpers.PRIORITY `json:"priority" gorm:"size:20;default:'NORMAL';not null"`
+	PriorityFrom      *time.Time       `json:"priorityFrom"`
+	AssignedCounterID *int             `json:"assignedCounterId"`
+	OrderAt           time.Time        `json:"orderAt" gorm:"default:current_timestamp"`
+	CounterID         *int             `json:"counterId" gorm:"foreignKey:CounterID;constraint:OnDelete:CASCADE"`
+	Feedback          bool             `json:"feedback" gorm:"default:false;not null"`
+	ServiceDate       time.Time        `json:"serviceDate" gorm:"type:date"`
+	CalledAt          *time.Time       `json:"calledAt"`
+	LastCalledAt      *time.Time       `json:"lastCalledAt"`
+	RecallCount       int              `json:"recallCount" gorm:"default:0;not null"`
+	RequeueCount      int              `json:"requeueCount" gorm:"default:0;not null"`
+	DeferCount        int              `json:"deferCount" gorm:"default:0;not null"`
+	ConfirmedAt       *time.Time       `json:"confirmedAt"`
+	ServedAt          *time.Time       `json:"servedAt"`
+	FinishedAt        *time.Time       `json:"finishedAt"`
+	HeldAt            *time.Time       `json:"heldAt"`
+	HoldUntil         *time.Time       `json:"holdUntil"`
+	HoldReason        *string          `json:"holdReason" gorm:"size:255"`
+	Members           []QueueMember    `json:"members,omitempty" gorm:"foreignKey:QueueID;constraint:OnDelete:CASCADE"`
+	Attachments       []Attachment     `json:"attachments,omitempty" gorm:"foreignKey:QueueID;constraint:OnDelete:CASCADE"`
+	CreatedAt         time.Time        `json:"createdAt" gorm:"default:current_timestamp"`
+}
+
+func (q Queue) MarshalJSON() ([]byte, error) {
+	type queueJSON Queue
+	return json.Marshal(struct {
+		queueJSON
+		Status helpers.STATUS `json:"status"`
+		State  helpers.STATUS `json:"state"`
+	}{queueJSON(q), q.Status.Legacy(), q.Status})
+}
+
+type QueueMember struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	QueueID   int       `json:"queueId" gorm:"index;not null"`
+	StudentID *string   `json:"studentId" gorm:"size:9;index"`
+	Firstname string    `json:"firstName" gorm:"not null"`
+	Lastname  string    `json:"lastName" gorm:"not null"`
+	Feedback  bool      `json:"feedback" gorm:"default:false;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"default:current_timestamp"`
+}
+
+type Attachment struct {
+	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	QueueID     int       `json:"queueId" gorm:"index;not null"`
+	FileName    string    `json:"fileName" gorm:"size:255;not null"`
+	ContentType string    `json:"contentType" gorm:"size:100;not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	StorageKey  string    `json:"-" gorm:"size:255;unique;not null"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"default:current_timestamp"`
+}
+
+type QueueEvent struct {
+	ID         int                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	QueueID    int                 `json:"queueId" gorm:"index;not null"`
+	Queue      Queue               `json:"-" gorm:"foreignKey:QueueID;constraint:OnDelete:CASCADE"`
+	Type       helpers.QUEUE_EVENT `json:"type" gorm:"size:20;not null"`
+	FromStatus *helpers.STATUS     `json:"fromStatus" gorm:"size:20"`
+	ToStatus   helpers.STATUS      `json:"toStatus" gorm:"size:20;not null"`
+	CounterID  *int                `json:"counterId"`
+	UserID     *int                `json:"userId"`
+	ActorEmail *string             `json:"actorEmail" gorm:"size:255"`
+	Data       json.RawMessage     `json:"data" gorm:"type:jsonb"`
+	CreatedAt  time.Time           `json:"createdAt" gorm:"index;default:current_timestamp"`
+}
+
+type QueueTransfer struct {
+	ID            int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	QueueID       int       `json:"queueId" gorm:"index;not null"`
+	Queue         Queue     `json:"-" gorm:"foreignKey:QueueID;constraint:OnDelete:CASCADE"`
+	FromTopicID   int       `json:"fromTopicId" gorm:"not null"`
+	ToTopicID     int       `json:"toTopicId" gorm:"not null"`
+	FromCounterID *int      `json:"fromCounterId"`
+	ToCounterID   *int      `json:"toCounterId"`
+	FromNo        string    `json:"fromNo" gorm:"not null"`
+	ToNo          string    `json:"toNo" gorm:"not null"`
+	KeepPosition  bool      `json:"keepPosition" gorm:"not null"`
+	Reason        *string   `json:"reason" gorm:"size:255"`
+	ActorEmail    *string   `json:"actorEmail" gorm:"size:255"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"default:current_timestamp"`
+}
+
+type QueueSequence struct {
+	TopicID     int       `json:"topicId" gorm:"primaryKey;autoIncrement:false"`
+	PeriodStart time.Time `json:"periodStart" gorm:"primaryKey;type:date"`
+	LastNo      int       `json:"lastNo" gorm:"not null"`
+}
+
+type Appointment struct {
+	ID        int                        `json:"id" gorm:"primaryKey;autoIncrement"`
+	TopicID   int                        `json:"topicId" gorm:"index;not null"`
+	Topic     Topic                      `json:"topic" gorm:"foreignKey:TopicID;constraint:OnDelete:CASCADE"`
+	StudentID string                     `json:"studentId" gorm:"size:9;index;not null"`
+	Firstname string                     `json:"firstName" gorm:"not null"`
+	Lastname  string                     `json:"lastName" gorm:"not null"`
+	Email     string                     `json:"email" gorm:"size:255"`
+	Note      *string                    `json:"note" gorm:"size:255"`
+	SlotStart time.Time                  `json:"slotStart" gorm:"index;not null"`
+	SlotEnd   time.Time                  `json:"slotEnd" gorm:"not null"`
+	Status    helpers.APPOINTMENT_STATUS `json:"status" gorm:"size:20;default:'BOOKED';not null"`
+	QueueID   *int                       `json:"queueId" gorm:"constraint:OnDelete:SET NULL"`
+	Queue     *Queue                     `json:"queue,omitempty" gorm:"foreignKey:QueueID;constraint:OnDelete:SET NULL"`
+	CreatedAt time.Time                  `json:"createdAt" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time                  `json:"updatedAt"`
+}
+
+type PriorityGrant struct {
+	ID        int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	Email     string           `json:"email" gorm:"size:255;uniqueIndex;not null"`
+	Priority  helpers.PRIORITY `json:"priority" gorm:"size:20;not null"`
+	Reason    *string          `json:"reason" gorm:"size:255"`
+	ExpiresAt *time.Time       `json:"expiresAt"`
+	CreatedAt time.Time        `json:"createdAt" gorm:"default:current_timestamp"`
 }
 
 type Feedback struct {
@@ -95,6 +252,34 @@ type NotiSchedule struct {
 	RepeatDays  pq.StringArray `json:"repeatDays" gorm:"type:text[];default:'{}'"`
 }
 
+type AuditLog struct {
+	ID         int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	ActorEmail *string         `json:"actorEmail" gorm:"size:100;index"`
+	ActorName  string          `json:"actorName" gorm:"size:200"`
+	ActorRole  string          `json:"actorRole" gorm:"size:20"`
+	Action     string          `json:"action" gorm:"size:50;not null;index"`
+	EntityType string          `json:"entityType" gorm:"size:50;not null;index"`
+	EntityID   string          `json:"entityId" gorm:"size:50;index"`
+	Before     json.RawMessage `json:"before" gorm:"type:jsonb"`
+	After      json.RawMessage `json:"after" gorm:"type:jsonb"`
+	Diff       json.RawMessage `json:"diff" gorm:"type:jsonb"`
+	IP         string          `json:"ip" gorm:"size:45"`
+	CreatedAt  time.Time       `json:"createdAt" gorm:"default:current_timestamp;index"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:255"`
+	Tokens    float64   `json:"tokens" gorm:"not null"`
+	Allowed   bool      `json:"allowed" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null"`
+}
+
+type RateLimitBlock struct {
+	Scope         string    `json:"scope" gorm:"primaryKey;size:50"`
+	Blocked       int64     `json:"blocked" gorm:"not null"`
+	LastBlockedAt time.Time `json:"lastBlockedAt" gorm:"not null"`
+}
+
 type UserWithoutCounter struct {
 	ID          int     `json:"id"`
 	FirstNameTH *string `json:"firstNameTH"`
@@ -108,6 +293,7 @@ type CounterResponse struct {
 	ID           int                `json:"id"`
 	Counter      string             `json:"counter"`
 	Status       bool               `json:"status"`
+	TimeOpened   string             `json:"timeOpened"`
 	TimeClosed   string             `json:"timeClosed"`
 	User         UserWithoutCounter `json:"user"`
 	Topics       []Topic            `json:"topics"`